@@ -0,0 +1,54 @@
+package dithering
+
+import (
+	"image"
+	"image/color"
+)
+
+// ANSI256Palette is the standard xterm 256 colors palette, in the order of the
+// ANSI color indices
+//
+// It holds the 16 system colors, the 6x6x6 color cube and 24 grays.
+var ANSI256Palette = ansi256Palette()
+
+// ansi256Palette builds the xterm 256 colors palette
+func ansi256Palette() color.Palette {
+	pal := make(color.Palette, 0, 256)
+	system := [16][3]uint8{
+		{0, 0, 0}, {128, 0, 0}, {0, 128, 0}, {128, 128, 0},
+		{0, 0, 128}, {128, 0, 128}, {0, 128, 128}, {192, 192, 192},
+		{128, 128, 128}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+		{0, 0, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+	}
+	for _, c := range system {
+		pal = append(pal, color.RGBA{c[0], c[1], c[2], 255})
+	}
+	levels := [6]uint8{0, 95, 135, 175, 215, 255}
+	for r := 0; r < 6; r++ {
+		for g := 0; g < 6; g++ {
+			for b := 0; b < 6; b++ {
+				pal = append(pal, color.RGBA{levels[r], levels[g], levels[b], 255})
+			}
+		}
+	}
+	for i := 0; i < 24; i++ {
+		v := uint8(8 + 10*i)
+		pal = append(pal, color.RGBA{v, v, v, 255})
+	}
+	return pal
+}
+
+// DrawToIndices applies an error diffusion algorithm to the rect part of src
+// toward ANSI256Palette
+//
+// It returns the ANSI color index of every pixel, row by row: the index of
+// the pixel at (x, y) is res[y-rect.Min.Y][x-rect.Min.X]
+func (dit Dither) DrawToIndices(rect image.Rectangle, src image.Image) [][]uint8 {
+	pix, stride := dit.DrawIndices(rect, src, ANSI256Palette)
+	res := make([][]uint8, rect.Dy())
+	for y := range res {
+		i := y * stride
+		res[y] = pix[i : i+rect.Dx() : i+rect.Dx()]
+	}
+	return res
+}
@@ -0,0 +1,37 @@
+package dithering
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDrawToIndices(t *testing.T) {
+	if len(ANSI256Palette) != 256 {
+		t.Fatalf("%d colors, want 256", len(ANSI256Palette))
+	}
+	r := image.Rect(-2, 3, 2, 5)
+	// colors held once by the palette, the cube repeats some system colors
+	for want, c := range map[uint8]color.RGBA{
+		0:   {0, 0, 0, 255},
+		9:   {255, 0, 0, 255},
+		15:  {255, 255, 255, 255},
+		7:   {192, 192, 192, 255},
+		17:  {0, 0, 95, 255},
+		208: {255, 135, 0, 255},
+		232: {8, 8, 8, 255},
+		243: {118, 118, 118, 255},
+	} {
+		rows := NewDither(FloydSteinberg).DrawToIndices(r, image.NewUniform(c))
+		if len(rows) != r.Dy() || len(rows[0]) != r.Dx() {
+			t.Fatalf("%dx%d indices, want %dx%d", len(rows[0]), len(rows), r.Dx(), r.Dy())
+		}
+		for _, row := range rows {
+			for _, index := range row {
+				if index != want {
+					t.Errorf("%v gives index %d, want %d", c, index, want)
+				}
+			}
+		}
+	}
+}
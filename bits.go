@@ -0,0 +1,68 @@
+package dithering
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+// ErrInvalidBits is returned when a channel bit depth is not between 1 and 8
+var ErrInvalidBits = errors.New("dithering: channel bit depth must be between 1 and 8")
+
+// bitLevels quantizes a channel to a uniform set of levels
+type bitLevels struct {
+	n int16
+}
+
+// quantize returns the level closest to v, v must be in [0, 255]
+func (l bitLevels) quantize(v int16) int16 {
+	k := (int32(v)*int32(l.n-1) + 127) / 255
+	return int16((k*255 + int32(l.n-1)/2) / int32(l.n-1))
+}
+
+// DrawBits applies an error diffusion algorithm to the src image toward the
+// uniform palette with the given bit depth per channel, like 5-6-5
+//
+// The closest levels are computed arithmetically, no palette is scanned.
+// The destination can be any draw.Image, it receives color.RGBA values.
+// IntMatrix, PerChannel, HighPrecision, LinearMatching, LinearError, Distance,
+// Invert and the transparency options are ignored.
+// It returns an error if a bit depth is not between 1 and 8
+func (dit Dither) DrawBits(dst draw.Image, rect image.Rectangle, src image.Image, bitsR, bitsG, bitsB int) error {
+	var levels [3]bitLevels
+	for ch, bits := range [3]int{bitsR, bitsG, bitsB} {
+		if bits < 1 || bits > 8 {
+			dit.endAnimation()
+			return fmt.Errorf("%w: %d", ErrInvalidBits, bits)
+		}
+		levels[ch] = bitLevels{1 << bits}
+	}
+	dit.bits = &levels
+	dit.IntMatrix = nil
+	dit.PerChannel, dit.HighPrecision, dit.LinearMatching, dit.LinearError = false, false, false, false
+	dit.Distance = nil
+	dit.Invert = false
+	dit.PreserveAlpha, dit.UseTransparentIndex = false, false
+	return dit.draw(context.Background(), dst, rect, src, nil, nil, nil)
+}
+
+// findBits quantizes every channel of a pixel given its carried error to the
+// closest level, the returned index is the packed 8-bit color 0xRRGGBB
+func findBits(err PixelError, r, g, b uint32, levels *[3]bitLevels, damping float32) (int, PixelError, uint32) {
+	pix := [3]int16{
+		clamp(int16(uint8(r>>8))+int16(clampFloat(err.R*damping, -255, 255)), 0, 255),
+		clamp(int16(uint8(g>>8))+int16(clampFloat(err.G*damping, -255, 255)), 0, 255),
+		clamp(int16(uint8(b>>8))+int16(clampFloat(err.B*damping, -255, 255)), 0, 255),
+	}
+	var q [3]int16
+	var distance uint32
+	for ch := range q {
+		q[ch] = levels[ch].quantize(pix[ch])
+		distance += uint32(abs(pix[ch] - q[ch]))
+	}
+	return int(q[0])<<16 | int(q[1])<<8 | int(q[2]),
+		PixelError{float32(pix[0] - q[0]), float32(pix[1] - q[1]), float32(pix[2] - q[2]), 1<<16 - 1},
+		distance
+}
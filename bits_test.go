@@ -0,0 +1,63 @@
+package dithering
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDrawBits(t *testing.T) {
+	src := gradient(64, 16)
+	dst := image.NewRGBA(image.Rect(0, 0, 32, 8))
+	// rect is clipped to the bounds of dst
+	if err := NewDither(FloydSteinberg).DrawBits(dst, src.Bounds(), src, 1, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	levels := [3]map[uint8]bool{{0: true, 255: true}, {0: true, 85: true, 170: true, 255: true}, {}}
+	for k := 0; k < 8; k++ {
+		levels[2][uint8((k*255+3)/7)] = true
+	}
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 32; x++ {
+			c := dst.RGBAAt(x, y)
+			if !levels[0][c.R] || !levels[1][c.G] || !levels[2][c.B] || c.A != 255 {
+				t.Fatalf("pixel (%d, %d) is %v, not a 1-2-3 color", x, y, c)
+			}
+		}
+	}
+	if c := dst.RGBAAt(31, 0); c == (color.RGBA{}) {
+		t.Error("the last column is not drawn")
+	}
+
+	if err := NewDither(FloydSteinberg).DrawBits(dst, dst.Bounds(), src, 0, 8, 8); !errors.Is(err, ErrInvalidBits) {
+		t.Errorf("got %v, want %v", err, ErrInvalidBits)
+	}
+}
+
+func TestDrawBits565(t *testing.T) {
+	src := colorful(64)
+	dst := image.NewRGBA(src.Rect)
+	if err := NewDither(FloydSteinberg).DrawBits(dst, src.Rect, src, 5, 6, 5); err != nil {
+		t.Fatal(err)
+	}
+	// every channel is one of the 2^bits evenly spaced levels
+	representable := func(v uint8, bits uint) bool {
+		n := 1<<bits - 1
+		k := (int(v)*n + 127) / 255
+		return (k*255+n/2)/n == int(v)
+	}
+	used := [3]map[uint8]bool{{}, {}, {}}
+	for i := 0; i < len(dst.Pix); i += 4 {
+		c := dst.Pix[i : i+4]
+		if !representable(c[0], 5) || !representable(c[1], 6) || !representable(c[2], 5) || c[3] != 255 {
+			t.Fatalf("pixel %d is %v, not a 5-6-5 color", i/4, c)
+		}
+		for ch := range used {
+			used[ch][c[ch]] = true
+		}
+	}
+	if len(used[0]) > 32 || len(used[1]) > 64 || len(used[2]) > 32 || len(used[1]) <= 32 {
+		t.Errorf("%d, %d and %d levels used", len(used[0]), len(used[1]), len(used[2]))
+	}
+}
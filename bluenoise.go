@@ -0,0 +1,188 @@
+package dithering
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+)
+
+// blueNoiseSize is the side of the default blue-noise mask
+const blueNoiseSize = 64
+
+// BlueNoise64 is the default 64x64 blue-noise threshold map
+//
+// It is the output of GenerateBlueNoise(64, 1)
+var BlueNoise64 = blueNoiseMask()
+
+// blueNoiseMask converts the embedded ranks to a threshold map
+func blueNoiseMask() [][]float32 {
+	res := make([][]float32, blueNoiseSize)
+	for y := range res {
+		res[y] = make([]float32, blueNoiseSize)
+		for x := range res[y] {
+			res[y][x] = float32(blueNoiseRanks[y*blueNoiseSize+x]) / (blueNoiseSize * blueNoiseSize)
+		}
+	}
+	return res
+}
+
+// NewBlueNoiseDither prepares a blue-noise ordered dithering algorithm
+//
+// Every pixel is thresholded against the mask tiled over the image, giving a
+// fine texture without error diffusion. A nil mask uses BlueNoise64.
+func NewBlueNoiseDither(mask [][]float32) OrderedDither {
+	if mask == nil {
+		mask = BlueNoise64
+	}
+	return NewOrderedDither(mask)
+}
+
+// ThresholdFromImage converts a grayscale mask image to a threshold map
+//
+// Black pixels give the lowest thresholds and white pixels the highest ones
+func ThresholdFromImage(img image.Image) [][]float32 {
+	b := img.Bounds()
+	res := make([][]float32, b.Dy())
+	for y := range res {
+		res[y] = make([]float32, b.Dx())
+		for x := range res[y] {
+			g := color.Gray16Model.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray16)
+			res[y][x] = float32(g.Y) / (1 << 16)
+		}
+	}
+	return res
+}
+
+// blueNoiseSigma is the standard deviation of the gaussian filter used to
+// find voids and clusters
+const blueNoiseSigma = 1.5
+
+// voidAndCluster holds the state of the void-and-cluster algorithm
+type voidAndCluster struct {
+	size   int
+	kernel []float64
+	energy []float64
+	on     []bool
+}
+
+// newVoidAndCluster prepares a toroidal size x size pattern
+func newVoidAndCluster(size int) *voidAndCluster {
+	v := &voidAndCluster{
+		size:   size,
+		kernel: make([]float64, size*size),
+		energy: make([]float64, size*size),
+		on:     make([]bool, size*size),
+	}
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dx, dy := x, y
+			if dx > size/2 {
+				dx -= size
+			}
+			if dy > size/2 {
+				dy -= size
+			}
+			v.kernel[y*size+x] = math.Exp(-float64(dx*dx+dy*dy) / (2 * blueNoiseSigma * blueNoiseSigma))
+		}
+	}
+	return v
+}
+
+// toggle flips a pixel and updates the energy of every pixel
+func (v *voidAndCluster) toggle(i int) {
+	v.on[i] = !v.on[i]
+	sign := 1.0
+	if !v.on[i] {
+		sign = -1
+	}
+	px, py := i%v.size, i/v.size
+	for y := 0; y < v.size; y++ {
+		ky := (y - py + v.size) % v.size
+		for x := 0; x < v.size; x++ {
+			kx := (x - px + v.size) % v.size
+			v.energy[y*v.size+x] += sign * v.kernel[ky*v.size+kx]
+		}
+	}
+}
+
+// tightestCluster returns the pixel set to state with the highest energy
+func (v *voidAndCluster) tightestCluster(state bool) int {
+	best := -1
+	for i, on := range v.on {
+		if on == state && (best < 0 || v.energy[i] > v.energy[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+// largestVoid returns the pixel set to state with the lowest energy
+func (v *voidAndCluster) largestVoid(state bool) int {
+	best := -1
+	for i, on := range v.on {
+		if on == state && (best < 0 || v.energy[i] < v.energy[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+// GenerateBlueNoise builds a size x size tileable blue-noise threshold map
+// with the void-and-cluster method
+//
+// The thresholds are evenly spaced in [0, 1), the same seed always produces
+// the same map. It returns nil if size is less than 1.
+func GenerateBlueNoise(size int, seed int64) [][]float32 {
+	if size < 1 {
+		return nil
+	}
+	n := size * size
+	rnd := rand.New(rand.NewSource(seed))
+	v := newVoidAndCluster(size)
+
+	// initial binary pattern, a tenth of the pixels are on
+	ones := n / 10
+	if ones < 1 {
+		ones = 1
+	}
+	for _, i := range rnd.Perm(n)[:ones] {
+		v.toggle(i)
+	}
+	for {
+		cluster := v.tightestCluster(true)
+		v.toggle(cluster)
+		void := v.largestVoid(false)
+		v.toggle(void)
+		if void == cluster {
+			break
+		}
+	}
+	// ranking the pixels of the initial pattern by removing its clusters,
+	// then the remaining ones by filling the voids
+	initial := append([]bool(nil), v.on...)
+	initialEnergy := append([]float64(nil), v.energy...)
+
+	rank := make([]int, n)
+	for r := ones - 1; r >= 0; r-- {
+		i := v.tightestCluster(true)
+		v.toggle(i)
+		rank[i] = r
+	}
+	copy(v.on, initial)
+	copy(v.energy, initialEnergy)
+	for r := ones; r < n; r++ {
+		i := v.largestVoid(false)
+		v.toggle(i)
+		rank[i] = r
+	}
+
+	res := make([][]float32, size)
+	for y := range res {
+		res[y] = make([]float32, size)
+		for x := range res[y] {
+			res[y][x] = float32(rank[y*size+x]) / float32(n)
+		}
+	}
+	return res
+}
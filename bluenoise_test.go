@@ -0,0 +1,106 @@
+package dithering
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestBlueNoiseDither(t *testing.T) {
+	r := image.Rect(0, 0, 128, 128)
+	for _, level := range []uint8{32, 96, 128, 200} {
+		dst := image.NewPaletted(r, blackWhite)
+		NewBlueNoiseDither(nil).Draw(dst, r, image.NewUniform(color.Gray{level}))
+		density := float64(bytes.Count(dst.Pix, []byte{1})) / float64(len(dst.Pix))
+		if want := float64(level) / 255; math.Abs(density-want) > 0.02 {
+			t.Errorf("gray %d: density %.3f, want %.3f", level, density, want)
+		}
+	}
+
+	// a mask supplied as an image gives the same thresholds
+	mask := image.NewGray16(image.Rect(0, 0, blueNoiseSize, blueNoiseSize))
+	for y, row := range BlueNoise64 {
+		for x, v := range row {
+			mask.SetGray16(x, y, color.Gray16{uint16(v * (1 << 16))})
+		}
+	}
+	src := gradient(128, 128)
+	got, want := image.NewPaletted(r, blackWhite), image.NewPaletted(r, blackWhite)
+	NewBlueNoiseDither(ThresholdFromImage(mask)).Draw(got, r, src)
+	NewBlueNoiseDither(nil).Draw(want, r, src)
+	if !bytes.Equal(got.Pix, want.Pix) {
+		t.Error("the mask image differs from BlueNoise64")
+	}
+
+	// the dithering is pointwise, concurrent draws share the mask safely
+	dit := NewBlueNoiseDither(nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dst := image.NewPaletted(r, blackWhite)
+			dit.Draw(dst, r, src)
+			if !bytes.Equal(dst.Pix, want.Pix) {
+				t.Error("a concurrent draw differs")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestGenerateBlueNoise(t *testing.T) {
+	const size = 32
+	mask := GenerateBlueNoise(size, 7)
+	again := GenerateBlueNoise(size, 7)
+	// the thresholds are a permutation of i/size², the same for a seed
+	seen := make([]bool, size*size)
+	var on []image.Point
+	for y, row := range mask {
+		for x, v := range row {
+			if v != again[y][x] {
+				t.Fatalf("(%d, %d) is %v then %v with the same seed", x, y, v, again[y][x])
+			}
+			i := int(v * size * size)
+			if float32(i)/(size*size) != v || i < 0 || i >= len(seen) || seen[i] {
+				t.Fatalf("(%d, %d) is %v, not a distinct multiple of 1/%d", x, y, v, size*size)
+			}
+			seen[i] = true
+			if v < 0.1 {
+				on = append(on, image.Pt(x, y))
+			}
+		}
+	}
+
+	// the lowest tenth of the thresholds are spread evenly: the mean distance
+	// to the nearest neighbor on the torus is about 2.5 while it is about 1.7
+	// for white noise
+	sum := 0.0
+	for _, p := range on {
+		nearest := math.Inf(1)
+		for _, q := range on {
+			if p == q {
+				continue
+			}
+			dx, dy := absInt(p.X-q.X), absInt(p.Y-q.Y)
+			if dx > size/2 {
+				dx = size - dx
+			}
+			if dy > size/2 {
+				dy = size - dy
+			}
+			nearest = math.Min(nearest, math.Hypot(float64(dx), float64(dy)))
+		}
+		sum += nearest
+	}
+	if mean := sum / float64(len(on)); mean < 2.2 {
+		t.Errorf("mean nearest neighbor distance %.2f, want at least 2.2", mean)
+	}
+
+	if GenerateBlueNoise(0, 1) != nil {
+		t.Error("GenerateBlueNoise(0, 1) is not nil")
+	}
+}
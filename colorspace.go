@@ -0,0 +1,204 @@
+package dithering
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// D65 reference white point
+const (
+	whiteX = 0.95047
+	whiteY = 1.0
+	whiteZ = 1.08883
+)
+
+// toLinear converts a gamma-encoded sRGB channel in [0, 1] to linear light
+func toLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// fromLinear converts a linear light channel in [0, 1] to gamma-encoded sRGB
+func fromLinear(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// toXYZ converts a color to CIE XYZ
+func toXYZ(c color.Color) (x, y, z float64) {
+	_r, _g, _b, _ := c.RGBA()
+	r := toLinear(float64(_r) / 0xffff)
+	g := toLinear(float64(_g) / 0xffff)
+	b := toLinear(float64(_b) / 0xffff)
+	x = 0.4124564*r + 0.3575761*g + 0.1804375*b
+	y = 0.2126729*r + 0.7151522*g + 0.0721750*b
+	z = 0.0193339*r + 0.1191920*g + 0.9503041*b
+	return x, y, z
+}
+
+// labF is the non linear function of the XYZ to CIELAB conversion
+func labF(t float64) float64 {
+	if t > 216.0/24389.0 {
+		return math.Cbrt(t)
+	}
+	return (24389.0/27.0*t + 16) / 116
+}
+
+// toLab converts a color to CIELAB using the D65 white point
+func toLab(c color.Color) (l, a, b float64) {
+	x, y, z := toXYZ(c)
+	fx := labF(x / whiteX)
+	fy := labF(y / whiteY)
+	fz := labF(z / whiteZ)
+	return 116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)
+}
+
+// deltaE2000 computes the CIEDE2000 color difference between two CIELAB colors
+func deltaE2000(l1, a1, b1, l2, a2, b2 float64) float64 {
+	const pow25to7 = 6103515625.0 // 25^7
+
+	c1 := math.Hypot(a1, b1)
+	c2 := math.Hypot(a2, b2)
+	cMean := (c1 + c2) / 2
+	cMean7 := math.Pow(cMean, 7)
+	g := 0.5 * (1 - math.Sqrt(cMean7/(cMean7+pow25to7)))
+
+	a1p := (1 + g) * a1
+	a2p := (1 + g) * a2
+	c1p := math.Hypot(a1p, b1)
+	c2p := math.Hypot(a2p, b2)
+
+	hue := func(a, b float64) float64 {
+		if a == 0 && b == 0 {
+			return 0
+		}
+		h := math.Atan2(b, a) * 180 / math.Pi
+		if h < 0 {
+			h += 360
+		}
+		return h
+	}
+	h1p := hue(a1p, b1)
+	h2p := hue(a2p, b2)
+
+	dLp := l2 - l1
+	dCp := c2p - c1p
+
+	var dhp float64
+	if c1p*c2p != 0 {
+		dhp = h2p - h1p
+		if dhp > 180 {
+			dhp -= 360
+		} else if dhp < -180 {
+			dhp += 360
+		}
+	}
+	dHp := 2 * math.Sqrt(c1p*c2p) * math.Sin(dhp*math.Pi/360)
+
+	lMeanp := (l1 + l2) / 2
+	cMeanp := (c1p + c2p) / 2
+
+	hMeanp := h1p + h2p
+	if c1p*c2p != 0 {
+		if math.Abs(h1p-h2p) > 180 {
+			if h1p+h2p < 360 {
+				hMeanp += 360
+			} else {
+				hMeanp -= 360
+			}
+		}
+		hMeanp /= 2
+	}
+
+	rad := math.Pi / 180
+	t := 1 - 0.17*math.Cos((hMeanp-30)*rad) +
+		0.24*math.Cos(2*hMeanp*rad) +
+		0.32*math.Cos((3*hMeanp+6)*rad) -
+		0.20*math.Cos((4*hMeanp-63)*rad)
+
+	dTheta := 30 * math.Exp(-math.Pow((hMeanp-275)/25, 2))
+	cMeanp7 := math.Pow(cMeanp, 7)
+	rc := 2 * math.Sqrt(cMeanp7/(cMeanp7+pow25to7))
+	lMean50 := (lMeanp - 50) * (lMeanp - 50)
+	sl := 1 + 0.015*lMean50/math.Sqrt(20+lMean50)
+	sc := 1 + 0.045*cMeanp
+	sh := 1 + 0.015*cMeanp*t
+	rt := -math.Sin(2*dTheta*rad) * rc
+
+	dl := dLp / sl
+	dc := dCp / sc
+	dh := dHp / sh
+	return math.Sqrt(dl*dl + dc*dc + dh*dh + rt*dc*dh)
+}
+
+// toLinearColor converts a gamma-encoded sRGB color to linear light
+func toLinearColor(c color.Color) color.RGBA64 {
+	r, g, b, a := c.RGBA()
+	return color.RGBA64{
+		uint16(toLinear(float64(r)/0xffff)*0xffff + 0.5),
+		uint16(toLinear(float64(g)/0xffff)*0xffff + 0.5),
+		uint16(toLinear(float64(b)/0xffff)*0xffff + 0.5),
+		uint16(a)}
+}
+
+// linearPalette converts every color of a palette to linear light
+func linearPalette(pal color.Palette) color.Palette {
+	res := make(color.Palette, len(pal))
+	for i, c := range pal {
+		res[i] = toLinearColor(c)
+	}
+	return res
+}
+
+// linearImage exposes an image in linear light
+type linearImage struct {
+	image.Image
+}
+
+// At returns the color of the pixel at (x, y) in linear light
+func (l linearImage) At(x, y int) color.Color {
+	return toLinearColor(l.Image.At(x, y))
+}
+
+// linear8 maps the 8-bit sRGB channels to linear light, scaled to [0, 255]
+var linear8 = linearTable()
+
+// linearTable builds the 8-bit sRGB to linear light table
+func linearTable() [256]float32 {
+	var t [256]float32
+	for i := range t {
+		t[i] = float32(toLinear(float64(i)/255) * 255)
+	}
+	return t
+}
+
+// findRGBLinear determines the closest color in a palette given the 16-bit
+// channels of the pixel, the error being in linear light
+//
+// The error is added to the pixel in linear light, the result is encoded
+// back to sRGB, clamped, to be matched and the returned error is the linear
+// difference between the unclamped pixel and the chosen color
+func findRGBLinear(err PixelError, r, g, b uint32, m matcher, damping float32) (int, PixelError, uint32) {
+	chans := [3]uint32{r, g, b}
+	errs := [3]float32{err.R, err.G, err.B}
+	var lin [3]float32
+	var pix [3]int16
+	for ch := range chans {
+		lin[ch] = linear8[uint8(chans[ch]>>8)] + clampFloat(errs[ch]*damping, -255, 255)
+		pix[ch] = int16(fromLinear(float64(clampFloat(lin[ch], 0, 255))/255)*255 + 0.5)
+	}
+
+	index, distance := m.closest(pix[0], pix[1], pix[2])
+	col := m.rgb[index]
+	return index,
+		PixelError{lin[0] - linear8[col[0]],
+			lin[1] - linear8[col[1]],
+			lin[2] - linear8[col[2]],
+			1<<16 - 1},
+		distance
+}
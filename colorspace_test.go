@@ -0,0 +1,47 @@
+package dithering
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestLinearMatchingDarkTones(t *testing.T) {
+	// in linear light the sRGB 6 is half of the sRGB 12, both below 1 in 8 bits
+	pal := color.Palette{color.Gray{0}, color.Gray{12}}
+	r := image.Rect(0, 0, 32, 32)
+	dst := image.NewPaletted(r, pal)
+	dit := NewDither(FloydSteinberg)
+	dit.ErrorDamping = 1
+	dit.LinearMatching = true
+	usage, err := dit.DrawWithUsage(dst, r, image.NewUniform(color.Gray{6}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := r.Dx() * r.Dy(); usage[1] < n*2/5 || usage[1] > n*3/5 {
+		t.Errorf("usage = %v, want about half of the pixels at index 1", usage)
+	}
+}
+
+func TestLinearError(t *testing.T) {
+	r := image.Rect(0, 0, 64, 64)
+	src := image.NewUniform(color.Gray{128})
+	// the share of white pixels of mid-gray
+	density := func(linear bool) float64 {
+		dit := NewDither(FloydSteinberg)
+		dit.ErrorDamping = 1
+		dit.LinearError = linear
+		dst := image.NewPaletted(r, blackWhite)
+		dit.Draw(dst, r, src)
+		return float64(bytes.Count(dst.Pix, []byte{1})) / float64(len(dst.Pix))
+	}
+	// sRGB mid-gray is 0.5 encoded but 0.216 in linear light
+	if d := density(false); math.Abs(d-0.5) > 0.03 {
+		t.Errorf("density %.3f in sRGB, want 0.5", d)
+	}
+	if d := density(true); math.Abs(d-0.216) > 0.03 {
+		t.Errorf("density %.3f in linear light, want 0.216", d)
+	}
+}
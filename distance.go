@@ -0,0 +1,82 @@
+package dithering
+
+import (
+	"image/color"
+	"math"
+)
+
+// rgb8 returns the 8-bit channels of a color
+func rgb8(c color.Color) (r, g, b int32) {
+	_r, _g, _b, _ := c.RGBA()
+	return int32(_r >> 8), int32(_g >> 8), int32(_b >> 8)
+}
+
+// EuclideanDistance is the squared euclidean distance between two colors
+// in 8-bit RGB space
+func EuclideanDistance(a, b color.Color) uint32 {
+	r1, g1, b1 := rgb8(a)
+	r2, g2, b2 := rgb8(b)
+	dr, dg, db := r1-r2, g1-g2, b1-b2
+	return uint32(dr*dr + dg*dg + db*db)
+}
+
+// DeltaE2000Distance is the CIEDE2000 difference between two colors
+// in CIELAB space, multiplied by 1000 to keep precision
+func DeltaE2000Distance(a, b color.Color) uint32 {
+	l1, a1, b1 := toLab(a)
+	l2, a2, b2 := toLab(b)
+	return uint32(deltaE2000(l1, a1, b1, l2, a2, b2)*1000 + 0.5)
+}
+
+// RedmeanDistance is the squared "redmean" distance between two colors
+//
+// It is a low-cost approximation of perceptual distance weighting
+// the channels according to the mean red level.
+func RedmeanDistance(a, b color.Color) uint32 {
+	r1, g1, b1 := rgb8(a)
+	r2, g2, b2 := rgb8(b)
+	rMean := (r1 + r2) / 2
+	dr, dg, db := r1-r2, g1-g2, b1-b2
+	return uint32((((512 + rMean) * dr * dr) >> 8) + 4*dg*dg + (((767 - rMean) * db * db) >> 8))
+}
+
+// lumaWeight is the weight of luma relative to chroma in YCbCrDistance
+const lumaWeight = 4
+
+// YCbCrDistance is the weighted squared distance between two colors in
+// BT.601 Y'CbCr space, luma weighs more than chroma
+func YCbCrDistance(a, b color.Color) uint32 {
+	r1, g1, b1 := rgb8(a)
+	r2, g2, b2 := rgb8(b)
+	y1, cb1, cr1 := color.RGBToYCbCr(uint8(r1), uint8(g1), uint8(b1))
+	y2, cb2, cr2 := color.RGBToYCbCr(uint8(r2), uint8(g2), uint8(b2))
+	dy, dcb, dcr := int32(y1)-int32(y2), int32(cb1)-int32(cb2), int32(cr1)-int32(cr2)
+	return uint32(lumaWeight*dy*dy + dcb*dcb + dcr*dcr)
+}
+
+// Weights of the hue and of the saturation relative to the value in HSVDistance
+const (
+	hsvHueWeight        = 4
+	hsvSaturationWeight = 1
+)
+
+// HSVDistance is the weighted squared distance between two colors in HSV
+// space, multiplied by 1000000 to keep precision
+//
+// The hue difference goes around the color wheel and weighs more than the
+// saturation and the value, so matching keeps the hue of the pixels at the
+// expense of their lightness. It is scaled by the lowest saturation since
+// the hue of grays is meaningless.
+func HSVDistance(a, b color.Color) uint32 {
+	r1, g1, b1 := rgb8(a)
+	r2, g2, b2 := rgb8(b)
+	h1, s1, v1 := toHSV(uint8(r1), uint8(g1), uint8(b1))
+	h2, s2, v2 := toHSV(uint8(r2), uint8(g2), uint8(b2))
+	dh := math.Abs(float64(h1 - h2))
+	if dh > 180 {
+		dh = 360 - dh
+	}
+	dh = dh / 180 * math.Min(float64(s1), float64(s2))
+	ds, dv := float64(s1-s2), float64(v1-v2)
+	return uint32((hsvHueWeight*dh*dh+hsvSaturationWeight*ds*ds+dv*dv)*1000000 + 0.5)
+}
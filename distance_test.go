@@ -0,0 +1,122 @@
+package dithering
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// nearest returns the index of the color of pal closest to c according to dist
+func nearest(dist DistanceFunc, pal color.Palette, c color.Color) int {
+	best := 0
+	for i := range pal {
+		if dist(c, pal[i]) < dist(c, pal[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+func TestDistanceFunc(t *testing.T) {
+	src := image.NewUniform(color.Gray{50})
+	r := image.Rect(0, 0, 4, 4)
+	dst := image.NewPaletted(r, blackWhite)
+	dit := NewDither(FloydSteinberg)
+	dit.ErrorDamping = 0
+	// the farthest color is the closest one
+	dit.Distance = func(a, b color.Color) uint32 { return 1<<20 - EuclideanDistance(a, b) }
+	dit.Draw(dst, r, src)
+	if dst.Pix[0] != 1 {
+		t.Errorf("index %d, want 1 chosen by the custom distance", dst.Pix[0])
+	}
+}
+
+func TestEuclideanDistance(t *testing.T) {
+	manhattan := func(a, b color.Color) uint32 {
+		r1, g1, b1 := rgb8(a)
+		r2, g2, b2 := rgb8(b)
+		return uint32(abs32(r1-r2) + abs32(g1-g2) + abs32(b1-b2))
+	}
+	// the olive is farther on the sum of the channels but closer in space
+	pal := color.Palette{color.RGBA{90, 90, 0, 255}, color.RGBA{0, 0, 150, 255}}
+	if i := nearest(manhattan, pal, color.Black); i != 1 {
+		t.Errorf("manhattan picks %d, want 1", i)
+	}
+	if i := nearest(EuclideanDistance, pal, color.Black); i != 0 {
+		t.Errorf("euclidean picks %d, want 0", i)
+	}
+	if d := EuclideanDistance(color.Gray16{0x8000}, color.Gray{0x80}); d != 0 {
+		t.Errorf("16-bit and 8-bit grays at distance %d", d)
+	}
+}
+
+func TestDeltaE2000(t *testing.T) {
+	// reference pairs of Sharma, Wu and Dalal
+	for _, c := range []struct {
+		lab1, lab2 [3]float64
+		want       float64
+	}{
+		{[3]float64{50, 2.6772, -79.7751}, [3]float64{50, 0, -82.7485}, 2.0425},
+		{[3]float64{50, 3.1571, -77.2803}, [3]float64{50, 0, -82.7485}, 2.8615},
+		{[3]float64{50, 0, 0}, [3]float64{50, -1, 2}, 2.3669},
+		{[3]float64{50, 2.5, 0}, [3]float64{73, 25, -18}, 27.1492},
+		{[3]float64{60.2574, -34.0099, 36.2677}, [3]float64{60.4626, -34.1751, 39.4387}, 1.2644},
+	} {
+		got := deltaE2000(c.lab1[0], c.lab1[1], c.lab1[2], c.lab2[0], c.lab2[1], c.lab2[2])
+		if math.Abs(got-c.want) > 1e-4 {
+			t.Errorf("deltaE2000(%v, %v) = %v, want %v", c.lab1, c.lab2, got, c.want)
+		}
+	}
+	if l, a, b := toLab(color.White); math.Abs(l-100) > 1e-3 || math.Abs(a) > 1e-3 || math.Abs(b) > 1e-3 {
+		t.Errorf("white is (%v, %v, %v) in CIELAB", l, a, b)
+	}
+	if d := DeltaE2000Distance(color.Black, color.White); d != 100000 {
+		t.Errorf("black and white at distance %d, want 100000", d)
+	}
+}
+
+func TestRedmeanDistance(t *testing.T) {
+	// the warm color is closer in plain RGB, redmean weighs the red
+	// difference more for reddish colors
+	pal := color.Palette{color.RGBA{240, 140, 80, 255}, color.RGBA{180, 100, 160, 255}}
+	c := color.RGBA{200, 100, 100, 255}
+	if i := nearest(EuclideanDistance, pal, c); i != 0 {
+		t.Errorf("euclidean picks %d, want 0", i)
+	}
+	if i := nearest(RedmeanDistance, pal, c); i != 1 {
+		t.Errorf("redmean picks %d, want 1", i)
+	}
+}
+
+func TestYCbCrDistance(t *testing.T) {
+	// the red has the luma of the gray, the darker gray has its chroma
+	pal := color.Palette{color.RGBA{190, 100, 128, 255}, color.Gray{100}}
+	c := color.Gray{128}
+	if i := nearest(EuclideanDistance, pal, c); i != 1 {
+		t.Errorf("euclidean picks %d, want 1", i)
+	}
+	if i := nearest(YCbCrDistance, pal, c); i != 0 {
+		t.Errorf("YCbCr picks %d, want the same luma 0", i)
+	}
+}
+
+func TestHSVDistance(t *testing.T) {
+	// a lighter red keeps the hue, the orange of the same value is closer in RGB
+	c := color.RGBA{160, 20, 20, 255}
+	pal := color.Palette{color.RGBA{160, 59, 20, 255}, color.RGBA{200, 25, 25, 255}}
+	if i := nearest(EuclideanDistance, pal, c); i != 0 {
+		t.Errorf("euclidean picks %d, want 0", i)
+	}
+	if i := nearest(HSVDistance, pal, c); i != 1 {
+		t.Errorf("HSV picks %d, want the red 1", i)
+	}
+	// the hue goes around the color wheel: 350° is closer to 10° than 60°
+	red, pink, yellow := color.RGBA{255, 43, 0, 255}, color.RGBA{255, 0, 43, 255}, color.RGBA{255, 255, 0, 255}
+	if d1, d2 := HSVDistance(red, pink), HSVDistance(red, yellow); d1 >= d2 {
+		t.Errorf("distance %d across 0°, %d to 60°", d1, d2)
+	}
+	if d := HSVDistance(c, c); d != 0 {
+		t.Errorf("distance %d between identical colors", d)
+	}
+}
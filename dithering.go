@@ -2,6 +2,9 @@
 package dithering
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
@@ -24,27 +27,340 @@ var (
 	TwoRowSierra = [][]float32{{0, 0, 0, 4.0 / 16.0, 3.0 / 16.0}, {1.0 / 32.0, 2.0 / 32.0, 3.0 / 32.0, 2.0 / 32.0, 1.0 / 32.0}}
 	// SierraLite is a variant of the Sierra matrix
 	SierraLite = [][]float32{{0, 0, 2.0 / 4.0}, {1.0 / 4.0, 1.0 / 4.0, 0}}
+	// StevensonArce is the Stevenson Arce matrix
+	//
+	// It was designed for hexagonal grids, its weights skip every other column
+	StevensonArce = [][]float32{
+		{0, 0, 0, 0, 0, 32.0 / 200.0, 0},
+		{12.0 / 200.0, 0, 26.0 / 200.0, 0, 30.0 / 200.0, 0, 16.0 / 200.0},
+		{0, 12.0 / 200.0, 0, 26.0 / 200.0, 0, 12.0 / 200.0, 0},
+		{5.0 / 200.0, 0, 12.0 / 200.0, 0, 12.0 / 200.0, 0, 5.0 / 200.0}}
+	// ShiauFan is the Shiau Fan matrix
+	ShiauFan = [][]float32{{0, 0, 0, 4.0 / 8.0}, {1.0 / 8.0, 1.0 / 8.0, 2.0 / 8.0, 0}}
+	// ShiauFan2 is a variant of the Shiau Fan matrix spreading the error further left
+	ShiauFan2 = [][]float32{{0, 0, 0, 0, 8.0 / 16.0}, {1.0 / 16.0, 1.0 / 16.0, 2.0 / 16.0, 4.0 / 16.0, 0}}
 )
 
+var (
+	// ErrDestinationNotPaletted is returned when the destination image is not an *image.Paletted
+	ErrDestinationNotPaletted = errors.New("dithering: destination image is not paletted")
+	// ErrEmptyPalette is returned when the palette has no color to match against
+	ErrEmptyPalette = errors.New("dithering: empty palette")
+)
+
+// DistanceFunc measures how far two colors are from each other
+//
+// Smaller values mean closer colors, identical colors should be at distance 0
+type DistanceFunc func(a, b color.Color) uint32
+
+// BorderPolicy tells what happens to the error diffused past the border of the image
+type BorderPolicy int
+
+const (
+	// Discard drops the error diffused out of the image, the pixels close to
+	// the borders are slightly biased toward the source but nothing is moved
+	Discard BorderPolicy = iota
+	// Clamp folds the error diffused out of the image onto the closest pixel
+	// still to be processed, the error is conserved but accumulates along the
+	// borders
+	Clamp
+)
+
+// target returns the pixel receiving the error diffused from (x, y) to (nx, ny)
+//
+// It returns false when the error is dropped
+func (b BorderPolicy) target(rect image.Rectangle, x, y, dir, nx, ny int) (int, int, bool) {
+	if b != Clamp {
+		return nx, ny, image.Point{nx, ny}.In(rect)
+	}
+	nx = clampInt(nx, rect.Min.X, rect.Max.X-1)
+	ny = clampInt(ny, rect.Min.Y, rect.Max.Y-1)
+	if ny == y && (nx-x)*dir <= 0 {
+		// the pixel is already processed, using the next one in scan order
+		if y+1 < rect.Max.Y {
+			ny = y + 1
+		} else if nx = x + dir; nx < rect.Min.X || nx >= rect.Max.X {
+			return 0, 0, false
+		}
+	}
+	return nx, ny, true
+}
+
 // Dither represent dithering algorithm implementation
+//
+// Draw keeps its state in locals and never modifies the Dither, so the same
+// Dither can draw different images from several goroutines. Animations are the
+// exception: the frames of concurrent Draw calls would be mixed in the shared
+// channel. Copying a Dither also shares its animation channel, use Clone to
+// fork a configured Dither.
 type Dither struct {
 	// Matrix is the error diffusion matrix
-	Matrix    [][]float32
+	//
+	// A weight on the current pixel keeps that part of its error on the
+	// pixel: it is matched again with that part added and the neighbors
+	// receive the error of the second match
+	Matrix [][]float32
+	// PreserveAlpha keeps transparent source pixels transparent when the
+	// destination palette has a fully transparent entry
+	PreserveAlpha bool
+	// UseTransparentIndex writes TransparentIndex for the source pixels whose
+	// alpha is below half, whatever the color at that index, when it is a
+	// valid index. Those pixels diffuse no error and the other pixels never
+	// get that index, so a palette holding no other color gives
+	// ErrEmptyPalette. It takes precedence over PreserveAlpha.
+	UseTransparentIndex bool
+	// TransparentIndex is the palette index written for the transparent
+	// source pixels when UseTransparentIndex is set
+	TransparentIndex int
+	// ErrorDamping is the low-pass filter applied to the carried error
+	// before it is added to a pixel, 1 means undamped diffusion
+	ErrorDamping float32
+	// DiffusionStrength scales every weight of the matrix, from 0 for no
+	// diffusion to 1 for the full diffusion of the matrix
+	DiffusionStrength float32
+	// DitherRange restricts the dithering to the pixels whose source luminance,
+	// from 0 to 1, is between DitherRange[0] and DitherRange[1]. The other pixels
+	// are mapped to their closest color and diffuse no error. The zero value
+	// dithers every pixel.
+	DitherRange [2]float32
+	// EdgeThreshold attenuates the error diffused across edges when positive:
+	// when the source of a neighbor differs from the source of the current
+	// pixel by more than EdgeThreshold on a channel, in 8-bit units, its weight
+	// is scaled by EdgeThreshold over the difference. It is ignored when
+	// IntMatrix is set.
+	EdgeThreshold float32
+	// Serpentine alternates the scan direction on every row
+	Serpentine bool
+	// Distance compares colors when matching the palette,
+	// the sum of absolute channel differences is used when nil.
+	// Whatever the distance, of several equally close palette colors the one
+	// with the lowest index is chosen, so the result only depends on the
+	// palette order through the ties.
+	Distance DistanceFunc
+	// AutoLevels stretches every channel of the source to the full range
+	// before dithering, it helps low contrast sources
+	AutoLevels bool
+	// AutoLevelsClip is the fraction of the darkest and of the brightest
+	// pixels of each channel ignored by AutoLevels, like 0.01 for 1%
+	AutoLevelsClip float32
+	// Brightness is added to every channel of the source before dithering
+	Brightness int
+	// Contrast scales every channel of the source around mid-gray before
+	// dithering, 0 and 1 leave the source untouched
+	Contrast float32
+	// Gamma corrects the source with out = in^(1/Gamma) before dithering,
+	// 0 and 1 leave the source untouched
+	Gamma float32
+	// Saturation scales the distance of the source channels to their BT.709
+	// luma before dithering, values below 1 move the colors toward the
+	// Luminosity gray and values above 1 enhance them, 0 and 1 leave the
+	// source untouched
+	Saturation float32
+	// HueShift rotates the hue of the source before dithering, in degrees
+	HueShift float32
+	// UnsharpAmount is the strength of the unsharp mask sharpening the source
+	// before dithering, 0 disables it
+	UnsharpAmount float32
+	// UnsharpRadius is the radius of the blur of the unsharp mask, in pixels
+	UnsharpRadius int
+	// Grayscale converts the source to gray before dithering, it sets the
+	// tonal balance of colored sources on gray palettes
+	Grayscale GrayscaleMode
+	// LinearMatching compares colors and diffuses the error in linear light
+	// instead of gamma-encoded sRGB, Distance then receives linear colors.
+	// The linear channels keep their 16 bits like with HighPrecision, the dark
+	// tones would collapse to a few levels in 8 bits.
+	LinearMatching bool
+	// LinearError computes and diffuses the error in linear light while the
+	// colors are still matched in gamma-encoded sRGB. It is ignored with
+	// LinearMatching, which already works in linear light, and with
+	// IntMatrix, PerChannel and HighPrecision.
+	LinearError bool
+	// PerChannel quantizes and diffuses every channel on its own, then picks the
+	// palette color closest to the quantized channels. It suits separable
+	// palettes like a 3-3-2 palette and is ignored when IntMatrix is set.
+	PerChannel bool
+	// HighPrecision matches colors and diffuses the error with the full 16 bits
+	// of the channels instead of their high byte, it smooths 16-bit sources.
+	// It is ignored when IntMatrix or PerChannel is set, Cache has no effect with it.
+	HighPrecision bool
+	// IntMatrix diffuses the error with integer arithmetic instead of Matrix when not nil
+	IntMatrix *IntMatrix
+	// Border tells what happens to the error diffused past the border of rect
+	Border BorderPolicy
+	// Invert writes the palette color farthest from each matched color, the
+	// error is still diffused from the matched color. With a black and white
+	// palette it gives the negative of the regular output.
+	Invert bool
+	// Cache memoizes the palette matches, it helps images with large flat regions
+	Cache bool
+	// Parallelism is the number of goroutines drawing the rows in a staggered
+	// wavefront, each row following the previous one closely enough for the
+	// output to be identical to the serial scan. 0 and 1 draw serially.
+	// It is ignored with Serpentine and animations, Progress is only called
+	// once every row is drawn and the Min and Max of the error image reflect
+	// its final values only.
+	// dst must support concurrent Set on distinct pixels.
+	Parallelism int
+	// Passes is the number of times the image is dithered, 0 and 1 mean a
+	// single pass. Every extra pass dithers the source corrected by the
+	// accumulated difference between the blurred source and the blurred
+	// output of the previous pass, which evens out the tone drift left by
+	// the damped error. It is ignored by animations.
+	Passes int
+	// CarryError keeps the error left in the buffer of DrawReusing instead of
+	// resetting it, so that every frame of an animation starts with the
+	// residual error of the previous one. The buffer must then cover rect.
+	CarryError bool
+	// Progress is called after each row with the number of processed pixels
+	// and the total number of pixels when not nil
+	Progress  func(done, total int)
 	animation chan draw.Image
 	nbFrames  int
+	// bits quantizes the channels to uniform levels instead of matching
+	// palette colors when not nil, see DrawBits
+	bits *[3]bitLevels
 }
 
 // NewDither prepares a dithering algorithm
 func NewDither(matrix [][]float32) Dither {
-	return Dither{matrix, make(chan draw.Image), 1}
+	return Dither{Matrix: matrix, ErrorDamping: 0.75, DiffusionStrength: 1, animation: make(chan draw.Image), nbFrames: 1}
+}
+
+// NewThresholdDither prepares a plain thresholding algorithm
+//
+// Each pixel is mapped to its closest palette color, no error is diffused
+func NewThresholdDither() Dither {
+	return NewDither(nil)
 }
 
 // NewDitherAnimation prepares a dithering algorithm and animation
 //
 // you can retrieve every generated frames thanks to RetrieveFrame
-// Note: frames are shared using an unbuffered channel
+// Note: frames are shared using an unbuffered channel, Draw blocks until
+// each frame is retrieved so RetrieveFrame must be called from another goroutine
 func NewDitherAnimation(matrix [][]float32, nbFrames int) Dither {
-	return Dither{matrix, make(chan draw.Image), nbFrames}
+	if nbFrames < 1 {
+		nbFrames = 1
+	}
+	dit := NewDither(matrix)
+	dit.nbFrames = nbFrames
+	return dit
+}
+
+// RetrieveFrame waits for the next frame generated by Draw
+//
+// The frame is a copy of the destination, an *image.Paletted for paletted
+// destinations and an *image.RGBA otherwise, so it can be kept while Draw
+// goes on. It returns false once Draw has finished.
+// Dither prepared with NewDither never generate frames.
+func (dit Dither) RetrieveFrame() (draw.Image, bool) {
+	if !dit.isAnimated() {
+		return nil, false
+	}
+	frame := <-dit.animation
+	return frame, frame != nil
+}
+
+// snapshot returns a copy of the frame being drawn, Draw keeps writing to
+// dst once the frame is sent
+func snapshot(dst draw.Image) draw.Image {
+	if p, ok := dst.(*image.Paletted); ok {
+		return copyPaletted(p)
+	}
+	b := dst.Bounds()
+	res := image.NewRGBA(b)
+	draw.Draw(res, b, dst, b.Min, draw.Src)
+	return res
+}
+
+// isAnimated tells whether Draw has to send frames
+func (dit Dither) isAnimated() bool {
+	return dit.animation != nil && dit.nbFrames > 1
+}
+
+// endAnimation tells RetrieveFrame that Draw has finished, it does nothing
+// when dit is not animated
+func (dit Dither) endAnimation() {
+	if dit.isAnimated() {
+		dit.animation <- nil
+	}
+}
+
+// paletteOf returns the palette of a paletted destination
+//
+// The animation is ended when dst is not paletted, RetrieveFrame would
+// otherwise wait for frames that are never drawn
+func (dit Dither) paletteOf(dst draw.Image) (color.Palette, error) {
+	pal, err := paletteOf(dst)
+	if err != nil {
+		dit.endAnimation()
+	}
+	return pal, err
+}
+
+// Clone returns a copy of dit with its own matrices and animation channel
+func (dit Dither) Clone() Dither {
+	clone := dit
+	clone.Matrix = copyMatrix(dit.Matrix)
+	if dit.IntMatrix != nil {
+		m := IntMatrix{make([][]int, len(dit.IntMatrix.Weights)), dit.IntMatrix.Divisor}
+		for i, row := range dit.IntMatrix.Weights {
+			m.Weights[i] = append([]int(nil), row...)
+		}
+		clone.IntMatrix = &m
+	}
+	if dit.animation != nil {
+		clone.animation = make(chan draw.Image)
+	}
+	return clone
+}
+
+// String summarizes the configuration of dit
+func (dit Dither) String() string {
+	cols := 0
+	if len(dit.Matrix) > 0 {
+		cols = len(dit.Matrix[0])
+	}
+	return fmt.Sprintf("Dither{matrix: %s %dx%d, serpentine: %t, damping: %v, frames: %d}",
+		dit.MatrixName(), cols, len(dit.Matrix), dit.Serpentine, dit.ErrorDamping, dit.nbFrames)
+}
+
+// copyMatrix returns a deep copy of a diffusion matrix
+func copyMatrix(matrix [][]float32) [][]float32 {
+	if matrix == nil {
+		return nil
+	}
+	res := make([][]float32, len(matrix))
+	for i, row := range matrix {
+		res[i] = append([]float32(nil), row...)
+	}
+	return res
+}
+
+// transparentIndex returns the index of the first fully transparent color of
+// the palette or -1 if there is none
+func transparentIndex(pal color.Palette) int {
+	for i, c := range pal {
+		if _, _, _, a := c.RGBA(); a == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// subPalette returns the colors of pal for which keep is true and their
+// index in pal
+func subPalette(pal color.Palette, keep func(i int, c color.Color) bool) (color.Palette, []int) {
+	res := make(color.Palette, 0, len(pal))
+	indices := make([]int, 0, len(pal))
+	for i, c := range pal {
+		if keep(i, c) {
+			res = append(res, c)
+			indices = append(indices, i)
+		}
+	}
+	return res, indices
 }
 
 // abs gives the absolute value of a signed integer
@@ -55,49 +371,354 @@ func abs(x int16) uint16 {
 	return uint16(x)
 }
 
-// findColor determines the closest color in a palette given the pixel color and the error
+// abs32 gives the absolute value of a 32-bit signed integer
+func abs32(x int32) int32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// clamp restricts a signed integer to the [min, max] range
+func clamp(x, min, max int16) int16 {
+	if x < min {
+		return min
+	}
+	if x > max {
+		return max
+	}
+	return x
+}
+
+// clampFloat restricts a float to the [min, max] range
+func clampFloat(x, min, max float32) float32 {
+	if x < min {
+		return min
+	}
+	if x > max {
+		return max
+	}
+	return x
+}
+
+// clampInt restricts an int to the [min, max] range
+func clampInt(x, min, max int) int {
+	if x < min {
+		return min
+	}
+	if x > max {
+		return max
+	}
+	return x
+}
+
+// opaqueRGBA converts a color to an opaque 8-bit color
+func opaqueRGBA(c color.Color) color.RGBA {
+	r, g, b, _ := c.RGBA()
+	return color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), 255}
+}
+
+// opaque returns the color written for a matched palette color
 //
-// It returns the closest color, the updated error and the distance between the error and the color
-func findColor(err color.Color, pix color.Color, pal color.Palette) (color.RGBA, PixelError, uint16) {
-	var errR, errG, errB,
-		pixR, pixG, pixB,
-		colR, colG, colB int16
-	_errR, _errG, _errB, _ := err.RGBA()
-	_pixR, _pixG, _pixB, _ := pix.RGBA()
+// Opaque colors are returned as is so that the destination receives the exact
+// palette entry, like a color.CMYK for a CMYK destination. Other colors are
+// made opaque.
+func opaque(c color.Color) color.Color {
+	if _, _, _, a := c.RGBA(); a == 1<<16-1 {
+		return c
+	}
+	return opaqueRGBA(c)
+}
 
-	// Low-pass filter
-	errR = int16(float32(int16(_errR)) * 0.75)
-	errG = int16(float32(int16(_errG)) * 0.75)
-	errB = int16(float32(int16(_errB)) * 0.75)
+// paletteWriter sets the pixels of a destination to palette colors given by
+// their index
+//
+// When the destination is an *image.Paletted using the palette, the indices
+// are written directly: Set would look every color up again in the palette
+// and could pick another entry.
+type paletteWriter struct {
+	dst      draw.Image
+	pal      color.Palette
+	paletted *image.Paletted
+	// packed tells that the indices are 0xRRGGBB colors, see findBits
+	packed bool
+}
 
-	pixR = int16(uint8(_pixR)) + errR
-	pixG = int16(uint8(_pixG)) + errG
-	pixB = int16(uint8(_pixB)) + errB
+// newPaletteWriter returns the paletteWriter setting the pixels of dst to the colors of pal
+func newPaletteWriter(dst draw.Image, pal color.Palette) paletteWriter {
+	w := paletteWriter{dst: dst, pal: pal}
+	if p, ok := dst.(*image.Paletted); ok && samePalette(p.Palette, pal) {
+		w.paletted = p
+	}
+	return w
+}
 
-	var index int
-	var minDiff uint16 = 1<<16 - 1
+// samePalette tells whether the palette of a paletted image is pal, the
+// colors are compared by value
+func samePalette(dstPal, pal color.Palette) bool {
+	if len(dstPal) != len(pal) || len(pal) > 256 {
+		return false
+	}
+	for i, c := range pal {
+		r1, g1, b1, a1 := c.RGBA()
+		r2, g2, b2, a2 := dstPal[i].RGBA()
+		if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+			return false
+		}
+	}
+	return true
+}
+
+// set sets the pixel at (x, y) to the color i of the palette, made opaque
+// when it is not a paletted destination
+func (w paletteWriter) set(x, y, i int) {
+	if w.packed {
+		w.dst.Set(x, y, color.RGBA{uint8(i >> 16), uint8(i >> 8), uint8(i), 255})
+		return
+	}
+	if w.paletted != nil {
+		w.paletted.SetColorIndex(x, y, uint8(i))
+		return
+	}
+	w.dst.Set(x, y, opaque(w.pal[i]))
+}
+
+// setAsIs sets the pixel at (x, y) to the color i of the palette, its alpha included
+func (w paletteWriter) setAsIs(x, y, i int) {
+	if w.paletted != nil {
+		w.paletted.SetColorIndex(x, y, uint8(i))
+		return
+	}
+	w.dst.Set(x, y, w.pal[i])
+}
+
+const (
+	// minTreePalette is the palette size from which matching uses a k-d tree
+	minTreePalette = 16
+	// maxCacheEntries bounds the lookup cache, it is cleared once full
+	maxCacheEntries = 4096
+)
+
+// cachedMatch is a previously computed closest color
+type cachedMatch struct {
+	index    int
+	distance uint32
+}
+
+// matcher finds the closest color of a palette
+type matcher struct {
+	pal color.Palette
+	// rgb holds the 8-bit channels of the palette colors
+	rgb [][3]int16
+	// rgb16 holds the 16-bit channels of the palette colors
+	rgb16 [][3]int32
+	// dist compares colors, the sum of absolute channel differences is used when nil
+	dist DistanceFunc
+	// tree speeds up the search for large palettes when dist is nil
+	tree *kdTree
+	// cache memoizes the closest colors when not nil
+	cache map[[3]int16]cachedMatch
+}
 
-	for i, col := range pal {
-		_colR, _colG, _colB, _ := col.RGBA()
+// newMatcher prepares the search of the closest color of a palette
+func newMatcher(pal color.Palette, dist DistanceFunc) matcher {
+	m := matcher{pal: pal, rgb: make([][3]int16, len(pal)), rgb16: make([][3]int32, len(pal)), dist: dist}
+	for i, c := range pal {
+		r, g, b, _ := c.RGBA()
+		m.rgb[i] = [3]int16{int16(r >> 8), int16(g >> 8), int16(b >> 8)}
+		m.rgb16[i] = [3]int32{int32(r), int32(g), int32(b)}
+	}
+	if dist == nil && len(pal) >= minTreePalette {
+		m.tree = newKDTree(m.rgb)
+	}
+	return m
+}
 
-		colR = int16(uint8(_colR))
-		colG = int16(uint8(_colG))
-		colB = int16(uint8(_colB))
-		var distance = abs(pixR-colR) + abs(pixG-colG) + abs(pixB-colB)
+// withCache enables the memoization of the closest colors
+//
+// The cache is not safe for concurrent use
+func (m matcher) withCache() matcher {
+	m.cache = make(map[[3]int16]cachedMatch)
+	return m
+}
 
+// closest returns the index of the closest color and its distance
+func (m matcher) closest(pixR, pixG, pixB int16) (int, uint32) {
+	if m.cache == nil {
+		return m.search(pixR, pixG, pixB)
+	}
+	key := [3]int16{pixR, pixG, pixB}
+	if c, ok := m.cache[key]; ok {
+		return c.index, c.distance
+	}
+	if len(m.cache) >= maxCacheEntries {
+		for k := range m.cache {
+			delete(m.cache, k)
+		}
+	}
+	index, distance := m.search(pixR, pixG, pixB)
+	m.cache[key] = cachedMatch{index, distance}
+	return index, distance
+}
+
+// search looks for the closest color, returning its index and distance
+//
+// Ties are broken in favor of the lowest index, like the k-d tree does
+func (m matcher) search(pixR, pixG, pixB int16) (int, uint32) {
+	if m.tree != nil {
+		return m.tree.nearest(pixR, pixG, pixB)
+	}
+
+	var index int
+	var minDiff uint32 = 1<<32 - 1
+
+	if m.dist != nil {
+		target := color.RGBA{uint8(pixR), uint8(pixG), uint8(pixB), 255}
+		for i, col := range m.pal {
+			if distance := m.dist(target, col); distance < minDiff {
+				index = i
+				minDiff = distance
+			}
+		}
+		return index, minDiff
+	}
+
+	for i, col := range m.rgb {
+		distance := uint32(abs(pixR-col[0]) + abs(pixG-col[1]) + abs(pixB-col[2]))
 		if distance < minDiff {
 			index = i
 			minDiff = distance
 		}
 	}
+	return index, minDiff
+}
 
-	_colR, _colG, _colB, _ := pal[index].RGBA()
+// inBand tells whether a pixel given by its 16-bit channels is dithered
+// according to DitherRange
+func (dit Dither) inBand(r, g, b uint32) bool {
+	if dit.DitherRange[1] <= dit.DitherRange[0] {
+		return true
+	}
+	lum := float32(Luminosity.gray(uint8(r>>8), uint8(g>>8), uint8(b>>8))) / 255
+	return lum >= dit.DitherRange[0] && lum <= dit.DitherRange[1]
+}
+
+// pixelReader returns the 16-bit channels of the pixel at (x, y)
+type pixelReader func(x, y int) (r, g, b, a uint32)
+
+// readerOf returns the pixelReader of src
+//
+// The pixels of the common concrete images are read directly, which avoids
+// boxing every pixel in a color.Color, and the color of an *image.Uniform
+// is only converted once
+func readerOf(src image.Image) pixelReader {
+	switch s := src.(type) {
+	case *image.Uniform:
+		r, g, b, a := s.C.RGBA()
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			return r, g, b, a
+		}
+	case *image.YCbCr:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			return s.YCbCrAt(x, y).RGBA()
+		}
+	case *image.RGBA:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			return s.RGBAAt(x, y).RGBA()
+		}
+	case *image.NRGBA:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			return s.NRGBAAt(x, y).RGBA()
+		}
+	case *image.Gray:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			return s.GrayAt(x, y).RGBA()
+		}
+	case *image.Paletted:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			// like At, the first color is returned outside of the bounds
+			return s.Palette[s.ColorIndexAt(x, y)].RGBA()
+		}
+	default:
+		return func(x, y int) (uint32, uint32, uint32, uint32) {
+			return src.At(x, y).RGBA()
+		}
+	}
+}
+
+// edgeFactor returns the factor applied to the error diffused to the pixel at
+// (x, y) of src from a pixel with the 16-bit channels r, g and b
+func edgeFactor(src pixelReader, x, y int, r, g, b uint32, threshold float32) float32 {
+	nr, ng, nb, _ := src(x, y)
+	diff := abs32(int32(r>>8) - int32(nr>>8))
+	if d := abs32(int32(g>>8) - int32(ng>>8)); d > diff {
+		diff = d
+	}
+	if d := abs32(int32(b>>8) - int32(nb>>8)); d > diff {
+		diff = d
+	}
+	if float32(diff) <= threshold {
+		return 1
+	}
+	return threshold / float32(diff)
+}
+
+// farthestColors returns the index of the farthest color of each palette color
+//
+// Colors are compared with the sum of absolute channel differences, ties are
+// broken in favor of the lowest index
+func farthestColors(pal color.Palette) []int {
+	res := make([]int, len(pal))
+	for i, c1 := range pal {
+		r1, g1, b1 := rgb8(c1)
+		var maxDiff int32 = -1
+		for j, c2 := range pal {
+			r2, g2, b2 := rgb8(c2)
+			if d := abs32(r1-r2) + abs32(g1-g2) + abs32(b1-b2); d > maxDiff {
+				res[i] = j
+				maxDiff = d
+			}
+		}
+	}
+	return res
+}
+
+// findColor determines the closest color in a palette given the pixel color and the error
+//
+// The error is damped by the given factor before being added to the pixel.
+// It returns the index of the closest color, the lowest one on ties, the
+// updated error and the distance between the error and the color
+func findColor(err PixelError, pix color.Color, m matcher, damping float32) (int, PixelError, uint32) {
+	r, g, b, _ := pix.RGBA()
+	return findRGB(err, r, g, b, m, damping)
+}
+
+// findRGB is findColor for a pixel given by its 16-bit channels
+func findRGB(err PixelError, r, g, b uint32, m matcher, damping float32) (int, PixelError, uint32) {
+	// Low-pass filter, the error is clamped so that it cannot wrap around
+	errR := int16(clampFloat(err.R*damping, -255, 255))
+	errG := int16(clampFloat(err.G*damping, -255, 255))
+	errB := int16(clampFloat(err.B*damping, -255, 255))
+
+	return matchRGB(errR, errG, errB, r, g, b, m)
+}
 
-	colR = int16(uint8(_colR))
-	colG = int16(uint8(_colG))
-	colB = int16(uint8(_colB))
+// matchRGB determines the closest color in a palette given the 16-bit channels
+// of the pixel and the already damped error
+func matchRGB(errR, errG, errB int16, r, g, b uint32, m matcher) (int, PixelError, uint32) {
+	var pixR, pixG, pixB,
+		colR, colG, colB int16
+
+	// RGBA returns 16-bit values, keep the high byte
+	pixR = clamp(int16(uint8(r>>8))+errR, 0, 255)
+	pixG = clamp(int16(uint8(g>>8))+errG, 0, 255)
+	pixB = clamp(int16(uint8(b>>8))+errB, 0, 255)
+
+	index, minDiff := m.closest(pixR, pixG, pixB)
+
+	colR, colG, colB = m.rgb[index][0], m.rgb[index][1], m.rgb[index][2]
 
-	return color.RGBA{uint8(colR), uint8(colG), uint8(colB), 255},
+	return index,
 		PixelError{float32(pixR - colR),
 			float32(pixG - colG),
 			float32(pixB - colB),
@@ -105,49 +726,384 @@ func findColor(err color.Color, pix color.Color, pal color.Palette) (color.RGBA,
 		minDiff
 }
 
+// findShift determines the horizontal offset between the diffusion matrix and the image
+//
+// The current pixel is conventionally the last zero of the first row before
+// its first weight. In a row of odd length, a weight at the center preceded
+// only by zeros is the weight of the current pixel, so the center is ignored
+// when looking for the first weight. When the first row has no other weight,
+// or when it is padded with zeros after its last weight so that the rows are
+// centered, like StevensonArce, the current pixel is the center of the row.
 func findShift(matrix [][]float32) int {
-	for _, v1 := range matrix {
-		for j, v2 := range v1 {
-			if v2 > 0.0 {
-				return -j + 1
+	if len(matrix) == 0 {
+		return 0
+	}
+	row := matrix[0]
+	center := len(row) / 2
+	odd := len(row)%2 == 1
+	first, last := -1, -1
+	for j, v := range row {
+		if v > 0.0 && !(odd && j == center) {
+			if first < 0 {
+				first = j
 			}
+			last = j
 		}
 	}
-	return 0
+	if first < 0 || (odd && center < first && (row[center] > 0.0 || last < len(row)-1)) {
+		return -center
+	}
+	return -first + 1
+}
+
+// centerWeight returns the weight of the current pixel in a diffusion matrix
+// offset by shift, it is zero for the usual matrices
+func centerWeight(matrix [][]float32, shift int) float32 {
+	if len(matrix) == 0 || -shift < 0 || -shift >= len(matrix[0]) {
+		return 0
+	}
+	return matrix[0][-shift]
 }
 
 // Draw applies an error diffusion algorithm to the src image
+//
+// Only the pixels of dst inside rect are drawn and the error is never diffused
+// outside of rect, the rest of dst is left untouched.
+// Errors are ignored, use DrawE to retrieve them
 func (dit Dither) Draw(dst draw.Image, rect image.Rectangle, src image.Image) {
+	_ = dit.DrawE(dst, rect, src)
+}
+
+// DrawE applies an error diffusion algorithm to the src image
+//
+// It returns an error if the destination is not paletted or if its palette is empty
+func (dit Dither) DrawE(dst draw.Image, rect image.Rectangle, src image.Image) error {
+	pal, err := dit.paletteOf(dst)
+	if err != nil {
+		return err
+	}
+	return dit.DrawWithPalette(dst, rect, src, pal)
+}
+
+// paletteDrawer is the DrawWithPalette method of a dithering algorithm
+type paletteDrawer func(dst draw.Image, rect image.Rectangle, src image.Image, pal color.Palette) error
+
+// drawE is the DrawE method shared by the dithering algorithms other than
+// Dither, drawWithPalette draws with the palette of the paletted destination
+func drawE(dst draw.Image, rect image.Rectangle, src image.Image, drawWithPalette paletteDrawer) error {
+	pal, err := paletteOf(dst)
+	if err != nil {
+		return err
+	}
+	return drawWithPalette(dst, rect, src, pal)
+}
+
+// paletteOf returns the palette of a paletted destination
+func paletteOf(dst draw.Image) (color.Palette, error) {
 	if _, ok := dst.(*image.Paletted); !ok {
-		return
+		return nil, ErrDestinationNotPaletted
 	}
-	p := dst.(*image.Paletted).Palette
+	return dst.(*image.Paletted).Palette, nil
+}
 
-	err := NewErrorImage(rect)
+// DrawWithPalette applies an error diffusion algorithm to the src image
+// using the given palette
+//
+// The destination can be any draw.Image, the chosen colors are set as is.
+// The palette is not limited to 256 colors when the destination is not an
+// *image.Paletted, large palettes are matched with a k-d tree.
+// It returns an error if the palette is empty
+func (dit Dither) DrawWithPalette(dst draw.Image, rect image.Rectangle, src image.Image, pal color.Palette) error {
+	return dit.draw(context.Background(), dst, rect, src, pal, nil, nil)
+}
+
+// DrawReusing applies an error diffusion algorithm to the src image
+// using buf to store the diffused error
+//
+// buf is reset before drawing so that successive calls, for instance on the
+// frames of a video, do not allocate nor share any error, unless CarryError
+// is set.
+// It returns an error if the destination is not paletted or if its palette is empty
+func (dit Dither) DrawReusing(buf *ErrorImage, dst draw.Image, rect image.Rectangle, src image.Image) error {
+	pal, err := dit.paletteOf(dst)
+	if err != nil {
+		return err
+	}
+	return dit.draw(context.Background(), dst, rect, src, pal, buf, nil)
+}
+
+// DrawCtx applies an error diffusion algorithm to the src image until ctx is done
+//
+// The context is checked before each row, on cancellation dst is left
+// partially drawn and the context error is returned.
+// It returns an error if the destination is not paletted or if its palette is empty
+func (dit Dither) DrawCtx(ctx context.Context, dst draw.Image, rect image.Rectangle, src image.Image) error {
+	pal, err := dit.paletteOf(dst)
+	if err != nil {
+		return err
+	}
+	return dit.draw(ctx, dst, rect, src, pal, nil, nil)
+}
+
+// draw is the error diffusion algorithm shared by the Draw methods
+//
+// The error is stored in buf when not nil, in a new ErrorImage otherwise.
+// The distances to the chosen colors are gathered in stats when not nil, the
+// rows are then drawn serially.
+// rect is restricted to the bounds of dst, nothing is read or written outside of it
+func (dit Dither) draw(ctx context.Context, dst draw.Image, rect image.Rectangle, src image.Image, pal color.Palette, buf *ErrorImage, stats *drawStats) error {
+	// the animation is ended on every return, errors included
+	defer dit.endAnimation()
+	if len(pal) == 0 && dit.bits == nil {
+		return ErrEmptyPalette
+	}
+	if dit.Passes > 1 && !dit.isAnimated() {
+		return dit.drawPasses(ctx, dst, rect, src, pal, buf, stats)
+	}
+	rect = rect.Intersect(dst.Bounds())
+	p := pal
+
+	transparent := -1
+	// palIndex maps the colors of p to their index in pal when p is not pal
+	var palIndex []int
+	if dit.UseTransparentIndex && dit.TransparentIndex >= 0 && dit.TransparentIndex < len(pal) {
+		if len(pal) == 1 {
+			return fmt.Errorf("%w: its only color is the transparent index", ErrEmptyPalette)
+		}
+		transparent = dit.TransparentIndex
+		p, palIndex = subPalette(pal, func(i int, c color.Color) bool {
+			return i != transparent
+		})
+	} else if dit.PreserveAlpha {
+		transparent = transparentIndex(pal)
+		if transparent >= 0 {
+			opaque, indices := subPalette(pal, func(i int, c color.Color) bool {
+				_, _, _, a := c.RGBA()
+				return a != 0
+			})
+			if len(opaque) > 0 {
+				p, palIndex = opaque, indices
+			}
+		}
+	}
+	out := newPaletteWriter(dst, pal)
+	out.packed = dit.bits != nil
+
+	src = dit.preprocess(src, rect)
+
+	// matching happens against lp, the chosen colors are taken from p
+	lp := p
+	if dit.LinearMatching {
+		lp = linearPalette(p)
+		src = linearImage{src}
+	}
+
+	m := newMatcher(lp, dit.Distance)
+	if dit.Cache {
+		m = m.withCache()
+	}
+	var levels *channelLevels
+	if dit.PerChannel {
+		levels = newChannelLevels(m.rgb)
+	}
+	var inverse []int
+	if dit.Invert {
+		inverse = farthestColors(p)
+	}
+	err := buf
+	if err == nil {
+		err = NewErrorImage(rect)
+	} else if !dit.CarryError {
+		err.Reset(rect)
+	}
 	shift := findShift(dit.Matrix)
+	center := centerWeight(dit.Matrix, shift) * dit.DiffusionStrength
+	var ints *intDiffusion
+	if dit.IntMatrix != nil {
+		ints = newIntDiffusion(*dit.IntMatrix, rect, dit.ErrorDamping, dit.Border)
+	}
 
-	pixPerFrame := (rect.Dx() * rect.Dy()) / dit.nbFrames
+	animated := dit.isAnimated()
+	frames := 0
+	pixPerFrame := 1
+	if animated {
+		pixPerFrame = (rect.Dx() * rect.Dy()) / dit.nbFrames
+		if pixPerFrame < 1 {
+			pixPerFrame = 1
+		}
+	}
 
-	for y := rect.Min.Y; y < rect.Max.Y; y++ {
-		for x := rect.Min.X; x < rect.Max.X; x++ {
-			// using the closest color
-			r, e, _ := findColor(err.PixelErrorAt(x, y), src.At(x, y), p)
-			dst.Set(x, y, r)
-			err.SetPixelError(x, y, e)
+	read := readerOf(src)
+	// match finds the closest color of a pixel given its carried error
+	match := func(m matcher, carried PixelError, r, g, b uint32) (int, PixelError, uint32) {
+		switch {
+		case dit.bits != nil:
+			return findBits(carried, r, g, b, dit.bits, dit.ErrorDamping)
+		case levels != nil:
+			return findRGBPerChannel(carried, r, g, b, m, levels, dit.ErrorDamping)
+		case dit.HighPrecision || dit.LinearMatching:
+			return findRGB16(carried, r, g, b, m, dit.ErrorDamping)
+		case dit.LinearError && !dit.LinearMatching:
+			return findRGBLinear(carried, r, g, b, m, dit.ErrorDamping)
+		default:
+			return findRGB(carried, r, g, b, m, dit.ErrorDamping)
+		}
+	}
+	// drawPixel dithers the pixel at (x, y) and diffuses its error, the
+	// bounds of err are only tracked when track is set
+	drawPixel := func(m matcher, x, y, dir int, track bool) {
+		r, g, b, a := read(x, y)
+		var e PixelError
+		if transparent >= 0 && a < 1<<15 {
+			// transparent pixels are kept as is and do not diffuse error
+			out.setAsIs(x, y, transparent)
+		} else {
+			// pixels outside of the dithering band ignore and diffuse no error
+			banded := dit.inBand(r, g, b)
+			carried := err.PixelErrorAt(x, y)
+			if !banded {
+				carried = PixelError{}
+			}
 
-			if (y != 0 && x != 0) && (((y*rect.Dy())+x)%pixPerFrame == 0) {
-				dit.animation <- dst
+			// using the closest color
+			var i int
+			var distance uint32
+			if ints != nil {
+				errR, errG, errB := ints.carried(x, y)
+				if !banded {
+					errR, errG, errB = 0, 0, 0
+				}
+				i, e, distance = matchRGB(errR, errG, errB, r, g, b, m)
+				if ints.center != 0 && banded {
+					// the error kept on the current pixel is added before matching it again
+					kept := e.Mul(ints.center * dit.DiffusionStrength)
+					i, e, distance = matchRGB(clamp(errR+int16(kept.R), -255, 255),
+						clamp(errG+int16(kept.G), -255, 255), clamp(errB+int16(kept.B), -255, 255), r, g, b, m)
+				}
+			} else {
+				i, e, distance = match(m, carried, r, g, b)
+				if center != 0 && banded && dit.ErrorDamping != 0 {
+					// the error kept on the current pixel is added before matching it again
+					i, e, distance = match(m, carried.Add(e.Mul(center/dit.ErrorDamping)), r, g, b)
+				}
 			}
+			if stats != nil {
+				stats.add(distance)
+			}
+			if !banded {
+				e = PixelError{}
+			}
+			if inverse != nil {
+				i = inverse[i]
+			}
+			if palIndex != nil {
+				i = palIndex[i]
+			}
+			out.set(x, y, i)
+		}
+
+		if ints != nil {
+			ints.diffuse(x, y, dir, e.Mul(dit.DiffusionStrength))
+			return
+		}
 
-			// diffusing the error using the diffusion matrix
-			for i, v1 := range dit.Matrix {
-				for j, v2 := range v1 {
-					err.SetPixelError(x+j+shift, y+i,
-						err.PixelErrorAt(x+j+shift, y+i).Add(err.PixelErrorAt(x, y).Mul(v2)))
+		// diffusing the error using the diffusion matrix
+		err.setPixelError(x, y, e, track)
+		for i, v1 := range dit.Matrix {
+			for j, v2 := range v1 {
+				if i == 0 && j+shift == 0 {
+					// the error kept on the current pixel is already used
+					continue
+				}
+				nx, ny, ok := dit.Border.target(rect, x, y, dir, x+dir*(j+shift), y+i)
+				if !ok {
+					continue
 				}
+				w := v2 * dit.DiffusionStrength
+				if dit.EdgeThreshold > 0 && w != 0 {
+					w *= edgeFactor(read, nx, ny, r, g, b, dit.EdgeThreshold)
+				}
+				err.setPixelError(nx, ny,
+					err.PixelErrorAt(nx, ny).Add(err.PixelErrorAt(x, y).Mul(w)), track)
+			}
+		}
+	}
+
+	if dit.Parallelism > 1 && !animated && !dit.Serpentine && stats == nil {
+		matrix := dit.Matrix
+		if ints != nil {
+			matrix = ints.matrix.Float()
+		}
+		left, right := matrixReach(matrix)
+		n := workers(dit.Parallelism, rect.Dy())
+		wavefrontErr := wavefront(ctx, rect, n, left+right+1, func() matcher {
+			if dit.Cache {
+				return m.withCache()
+			}
+			return m
+		}, func(m matcher, x, y int) {
+			drawPixel(m, x, y, 1, false)
+		})
+		err.updateBounds()
+		if wavefrontErr == nil && dit.Progress != nil {
+			dit.Progress(rect.Dx()*rect.Dy(), rect.Dx()*rect.Dy())
+		}
+		return wavefrontErr
+	}
+
+	pixIndex := 0
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		// on reversed rows the scan goes right to left and the matrix is mirrored
+		dir := 1
+		if dit.Serpentine && (y-rect.Min.Y)%2 == 1 {
+			dir = -1
+		}
+		for k := 0; k < rect.Dx(); k++ {
+			x := rect.Min.X + k
+			if dir < 0 {
+				x = rect.Max.X - 1 - k
 			}
+			drawPixel(m, x, y, dir, true)
+
+			// the last frame is sent once the whole image is drawn
+			pixIndex++
+			if animated && frames < dit.nbFrames-1 && pixIndex%pixPerFrame == 0 {
+				dit.animation <- snapshot(dst)
+				frames++
+			}
+		}
+		if dit.Progress != nil {
+			dit.Progress(pixIndex, rect.Dx()*rect.Dy())
 		}
 	}
+	if animated {
+		dit.animation <- snapshot(dst)
+	}
+	return nil
 }
 
+// Apply dithers the src image with the given palette and diffusion matrix
+//
+// It returns a new paletted image with the bounds of src
+func Apply(src image.Image, pal color.Palette, matrix [][]float32) *image.Paletted {
+	dst := image.NewPaletted(src.Bounds(), pal)
+	NewDither(matrix).Draw(dst, dst.Bounds(), src)
+	return dst
+}
 
+// DrawIndices applies an error diffusion algorithm to the rect part of src
+// with the given palette
+//
+// It returns the palette indices of the pixels, laid out like the Pix of an
+// *image.Paletted with the bounds rect: the index of the pixel at (x, y) is
+// pix[(y-rect.Min.Y)*stride+(x-rect.Min.X)]. The palette must not have more
+// than 256 colors.
+func (dit Dither) DrawIndices(rect image.Rectangle, src image.Image, pal color.Palette) (pix []uint8, stride int) {
+	dst := image.NewPaletted(rect, pal)
+	dit.Draw(dst, rect, src)
+	return dst.Pix, dst.Stride
+}
@@ -0,0 +1,997 @@
+package dithering
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gradient returns a w x h horizontal gray gradient
+func gradient(w, h int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetGray(x, y, color.Gray{uint8(x * 255 / (w - 1))})
+		}
+	}
+	return img
+}
+
+// blackWhite is the black and white palette
+var blackWhite = color.Palette{color.Black, color.White}
+
+// retrieveAll reads the frames of dit until the animation ends or the
+// timeout expires, it returns the number of frames and whether it ended
+func retrieveAll(dit Dither, timeout time.Duration) (int, bool) {
+	done := make(chan int)
+	go func() {
+		n := 0
+		for {
+			if _, ok := dit.RetrieveFrame(); !ok {
+				done <- n
+				return
+			}
+			n++
+		}
+	}()
+	select {
+	case n := <-done:
+		return n, true
+	case <-time.After(timeout):
+		return 0, false
+	}
+}
+
+func TestAnimationEndsOnError(t *testing.T) {
+	cases := map[string]func(dit Dither) error{
+		"not paletted": func(dit Dither) error {
+			return dit.DrawE(image.NewRGBA(image.Rect(0, 0, 8, 8)), image.Rect(0, 0, 8, 8), gradient(8, 8))
+		},
+		"empty palette": func(dit Dither) error {
+			dst := image.NewPaletted(image.Rect(0, 0, 8, 8), color.Palette{})
+			return dit.DrawE(dst, dst.Rect, gradient(8, 8))
+		},
+		"stats": func(dit Dither) error {
+			_, _, err := dit.DrawWithStats(image.NewRGBA(image.Rect(0, 0, 8, 8)), image.Rect(0, 0, 8, 8), gradient(8, 8))
+			return err
+		},
+	}
+	for name, draw := range cases {
+		t.Run(name, func(t *testing.T) {
+			dit := NewDitherAnimation(FloydSteinberg, 4)
+			errs := make(chan error, 1)
+			go func() { errs <- draw(dit) }()
+			if _, ok := retrieveAll(dit, time.Second); !ok {
+				t.Fatal("RetrieveFrame still blocked after the failed Draw")
+			}
+			if err := <-errs; err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+// algorithm is implemented by every dithering algorithm
+type algorithm interface {
+	DrawE(dst draw.Image, rect image.Rectangle, src image.Image) error
+	DrawWithPalette(dst draw.Image, rect image.Rectangle, src image.Image, pal color.Palette) error
+}
+
+// algorithms returns an instance of every dithering algorithm
+func algorithms() map[string]algorithm {
+	return map[string]algorithm{
+		"error diffusion": NewDither(FloydSteinberg),
+		"ordered":         NewOrderedDither(Bayer4),
+		"random":          NewRandomDither(1),
+		"riemersma":       NewRiemersmaDither(),
+		"ostromoukhov":    NewOstromoukhovDither(),
+		"dot diffusion":   NewDotDiffusion(),
+		"pattern":         NewPatternDither(4),
+	}
+}
+
+func TestDrawE(t *testing.T) {
+	src := gradient(16, 8)
+	for name, alg := range algorithms() {
+		t.Run(name, func(t *testing.T) {
+			if err := alg.DrawE(image.NewRGBA(src.Rect), src.Rect, src); !errors.Is(err, ErrDestinationNotPaletted) {
+				t.Errorf("not paletted: got %v", err)
+			}
+			if err := alg.DrawE(image.NewPaletted(src.Rect, nil), src.Rect, src); !errors.Is(err, ErrEmptyPalette) {
+				t.Errorf("empty palette: got %v", err)
+			}
+			got := image.NewPaletted(src.Rect, blackWhite)
+			if err := alg.DrawE(got, src.Rect, src); err != nil {
+				t.Fatal(err)
+			}
+			want := image.NewPaletted(src.Rect, blackWhite)
+			if err := alg.DrawWithPalette(want, src.Rect, src, blackWhite); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got.Pix, want.Pix) {
+				t.Error("DrawE and DrawWithPalette differ")
+			}
+		})
+	}
+}
+
+func TestPalettedIndices(t *testing.T) {
+	// the half transparent white and the gray are the same color to the
+	// matcher, the first one must be written even though Set would pick the
+	// gray
+	pal := color.Palette{color.Black, color.White, color.NRGBA{255, 255, 255, 128}, color.Gray{128}}
+	src := image.NewUniform(color.Gray{128})
+	r := image.Rect(0, 0, 16, 16)
+	dst := image.NewPaletted(r, pal)
+	usage, err := NewDither(FloydSteinberg).DrawWithUsage(dst, r, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage[2] != r.Dx()*r.Dy() {
+		t.Errorf("usage = %v, want every pixel at index 2", usage)
+	}
+
+	for name, alg := range algorithms() {
+		dst := image.NewPaletted(r, pal)
+		if err := alg.DrawE(dst, r, src); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if bytes.IndexByte(dst.Pix, 2) < 0 || bytes.IndexByte(dst.Pix, 3) >= 0 {
+			t.Errorf("%s: indices %v, want 2 instead of 3", name, dst.Pix[:r.Dx()])
+		}
+	}
+}
+
+func TestTransparentIndex(t *testing.T) {
+	r := image.Rect(0, 0, 8, 8)
+	src := image.NewNRGBA(r)
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 4; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{0, 0, 0, 255})
+		}
+	}
+
+	// the zero value does not reserve index 0
+	dst := image.NewPaletted(r, blackWhite)
+	dit := Dither{Matrix: FloydSteinberg}
+	dit.Draw(dst, r, src)
+	if dst.ColorIndexAt(0, 0) != 0 {
+		t.Errorf("zero value: index %d for black, want 0", dst.ColorIndexAt(0, 0))
+	}
+
+	dit = NewDither(FloydSteinberg)
+	dit.UseTransparentIndex, dit.TransparentIndex = true, 1
+	dit.Draw(dst, r, src)
+	if dst.ColorIndexAt(0, 0) != 0 || dst.ColorIndexAt(7, 7) != 1 {
+		t.Errorf("got indices %d and %d, want 0 for black and 1 for transparent", dst.ColorIndexAt(0, 0), dst.ColorIndexAt(7, 7))
+	}
+	// no color is left for the opaque pixels
+	single := image.NewPaletted(r, color.Palette{color.Transparent})
+	dit.TransparentIndex = 0
+	if err := dit.DrawE(single, r, src); !errors.Is(err, ErrEmptyPalette) {
+		t.Errorf("single transparent color: got %v, want %v", err, ErrEmptyPalette)
+	}
+}
+
+// colorful returns a size x size image mixing gradients of every channel
+func colorful(size int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.SetRGBA(x, y, color.RGBA{uint8(x * 255 / size), uint8(y * 255 / size), uint8((x ^ y) * 255 / size), 255})
+		}
+	}
+	return img
+}
+
+func BenchmarkDraw(b *testing.B) {
+	for _, size := range []int{256, 1024, 4096} {
+		src := colorful(size)
+		palettes := []color.Palette{blackWhite, PopularityPalette(src, 16, 4), PopularityPalette(src, 256, 4)}
+		for _, name := range MatrixNames() {
+			dit, err := DitherByName(name)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for _, pal := range palettes {
+				b.Run(fmt.Sprintf("%s/%dcolors/%dx%[3]d", name, len(pal), size), func(b *testing.B) {
+					dst := image.NewPaletted(src.Bounds(), pal)
+					b.ReportAllocs()
+					b.ResetTimer()
+					for i := 0; i < b.N; i++ {
+						dit.Draw(dst, dst.Bounds(), src)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestGray16Source(t *testing.T) {
+	// the high byte decides: 0x00ff is black and 0xff00 white
+	src := image.NewGray16(image.Rect(0, 0, 4, 2))
+	want := []uint8{0, 1, 0, 1, 1, 0, 1, 0}
+	for i, index := range want {
+		v := uint16(0x00ff)
+		if index == 1 {
+			v = 0xff00
+		}
+		src.SetGray16(i%4, i/4, color.Gray16{v})
+	}
+	dst := image.NewPaletted(src.Rect, blackWhite)
+	NewDither(FloydSteinberg).Draw(dst, src.Rect, src)
+	if !bytes.Equal(dst.Pix, want) {
+		t.Errorf("indices %v, want %v", dst.Pix, want)
+	}
+}
+
+func TestPreserveAlpha(t *testing.T) {
+	// the left half is cut out
+	src := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 4; x < 8; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{200, 200, 200, 255})
+		}
+	}
+	dst := image.NewPaletted(src.Rect, color.Palette{color.Black, color.White, color.Transparent})
+	dit := NewDither(FloydSteinberg)
+	dit.PreserveAlpha = true
+	dit.Draw(dst, src.Rect, src)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			want := uint32(0)
+			if x >= 4 {
+				want = 0xffff
+			}
+			if _, _, _, a := img.At(x, y).RGBA(); a != want {
+				t.Fatalf("pixel (%d, %d) has alpha %#x, want %#x", x, y, a, want)
+			}
+		}
+	}
+}
+
+func TestErrorDamping(t *testing.T) {
+	if d := NewDither(FloydSteinberg).ErrorDamping; d != 0.75 {
+		t.Errorf("default ErrorDamping %v, want 0.75", d)
+	}
+	src := gradient(64, 16)
+	draw := func(damping float32) []uint8 {
+		dst := image.NewPaletted(src.Rect, blackWhite)
+		dit := NewDither(FloydSteinberg)
+		dit.ErrorDamping = damping
+		dit.Draw(dst, src.Rect, src)
+		return dst.Pix
+	}
+	if bytes.Equal(draw(0.75), draw(1)) {
+		t.Error("ErrorDamping is ignored")
+	}
+	// without any error the pixels are thresholded
+	for i, index := range draw(0) {
+		if want := src.Pix[i] >= 128; (index == 1) != want {
+			t.Fatalf("pixel %d of level %d has index %d", i, src.Pix[i], index)
+		}
+	}
+}
+
+func TestAnimationOffset(t *testing.T) {
+	src := gradient(200, 200)
+	dst := image.NewPaletted(src.Rect, blackWhite)
+	dit := NewDitherAnimation(FloydSteinberg, 4)
+	go dit.Draw(dst, image.Rect(50, 50, 150, 150), src)
+	n, ok := retrieveAll(dit, 5*time.Second)
+	if !ok {
+		t.Fatal("the animation did not end")
+	}
+	if n != 4 {
+		t.Errorf("%d frames, want 4", n)
+	}
+}
+
+func TestAnimationFrames(t *testing.T) {
+	white := image.NewUniform(color.White)
+	dst := image.NewPaletted(image.Rect(0, 0, 64, 64), blackWhite)
+	dit := NewDitherAnimation(FloydSteinberg, 4)
+	go dit.Draw(dst, dst.Rect, white)
+	var frames []*image.Paletted
+	for {
+		frame, ok := dit.RetrieveFrame()
+		if !ok {
+			break
+		}
+		frames = append(frames, frame.(*image.Paletted))
+	}
+	if len(frames) != 4 {
+		t.Fatalf("%d frames, want 4", len(frames))
+	}
+	// the frames are kept as sent while Draw goes on
+	drawn := -1
+	for i, frame := range frames {
+		if frame == dst {
+			t.Fatalf("frame %d is the destination", i)
+		}
+		n := bytes.Count(frame.Pix, []byte{1})
+		if n <= drawn {
+			t.Errorf("frame %d has %d drawn pixels, the previous one %d", i, n, drawn)
+		}
+		drawn = n
+	}
+	if !bytes.Equal(frames[3].Pix, dst.Pix) {
+		t.Error("the last frame differs from the destination")
+	}
+}
+
+func TestFindShift(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		want int
+	}{{"floyd-steinberg", -1}, {"atkinson", -1}, {"jarvis-judice-ninke", -2}, {"stevenson-arce", -3}, {"shiau-fan-2", -3}} {
+		dit, _ := DitherByName(c.name)
+		if shift := findShift(dit.Matrix); shift != c.want {
+			t.Errorf("%s: shift %d, want %d", c.name, shift, c.want)
+		}
+	}
+	for _, name := range MatrixNames() {
+		dit, _ := DitherByName(name)
+		shift := findShift(dit.Matrix)
+		// the current pixel and the pixels before it on its row receive no error
+		for j, v := range dit.Matrix[0] {
+			if j+shift <= 0 && v != 0 {
+				t.Errorf("%s: weight %v at %d, before the current pixel", name, v, j+shift)
+			}
+		}
+		if -shift >= len(dit.Matrix[0]) {
+			t.Errorf("%s: current pixel outside of the matrix", name)
+		}
+	}
+}
+
+func TestSaturatedError(t *testing.T) {
+	// red is closer to black, the growing red error must not wrap around
+	src := image.NewUniform(color.RGBA{255, 0, 0, 255})
+	r := image.Rect(0, 0, 64, 64)
+	for _, damping := range []float32{0.75, 1} {
+		dst := image.NewPaletted(r, blackWhite)
+		dit := NewDither(FloydSteinberg)
+		dit.ErrorDamping = damping
+		dit.Draw(dst, r, src)
+		if i := bytes.IndexByte(dst.Pix, 1); i >= 0 {
+			t.Errorf("damping %v: white speckle at pixel %d", damping, i)
+		}
+	}
+}
+
+func TestDrawWithPaletteRGBA(t *testing.T) {
+	src := gradient(64, 16)
+	pal := color.Palette{color.Black, color.RGBA{255, 0, 0, 255}, color.White}
+	paletted := image.NewPaletted(src.Rect, pal)
+	NewDither(FloydSteinberg).Draw(paletted, src.Rect, src)
+	dst := image.NewRGBA(src.Rect)
+	if err := NewDither(FloydSteinberg).DrawWithPalette(dst, src.Rect, src, pal); err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 64; x++ {
+			if got, want := dst.At(x, y), color.RGBAModel.Convert(paletted.At(x, y)); got != want {
+				t.Fatalf("pixel (%d, %d) is %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestSerpentine(t *testing.T) {
+	src := gradient(64, 16)
+	raster := image.NewPaletted(src.Rect, blackWhite)
+	NewDither(FloydSteinberg).Draw(raster, src.Rect, src)
+	serpentine := image.NewPaletted(src.Rect, blackWhite)
+	dit := NewDither(FloydSteinberg)
+	dit.Serpentine = true
+	dit.Draw(serpentine, src.Rect, src)
+
+	// the first row is scanned left to right in both cases
+	if !bytes.Equal(raster.Pix[:64], serpentine.Pix[:64]) {
+		t.Error("the first rows differ")
+	}
+	if bytes.Equal(raster.Pix, serpentine.Pix) {
+		t.Error("the odd rows are not scanned right to left")
+	}
+	count := func(pix []uint8) int { return bytes.Count(pix, []byte{1}) }
+	if d := count(raster.Pix) - count(serpentine.Pix); d < -32 || d > 32 {
+		t.Errorf("%d more white pixels with the raster scan", d)
+	}
+}
+
+func TestThresholdDither(t *testing.T) {
+	src := gradient(64, 4)
+	pal := color.Palette{color.Black, color.Gray{100}, color.White}
+	m := newMatcher(pal, nil)
+	for _, dit := range []Dither{NewThresholdDither(), NewDither(nil), NewDither([][]float32{})} {
+		dst := image.NewPaletted(src.Rect, pal)
+		dit.Draw(dst, src.Rect, src)
+		for i, v := range src.Pix {
+			if want, _ := m.closest(int16(v), int16(v), int16(v)); int(dst.Pix[i]) != want {
+				t.Fatalf("level %d has index %d, want the closest %d", v, dst.Pix[i], want)
+			}
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	src := image.NewGray(image.Rect(10, 20, 42, 36))
+	pal := color.Palette{color.Black, color.Gray{128}, color.White}
+	dst := Apply(src, pal, FloydSteinberg)
+	if dst.Rect != src.Rect {
+		t.Errorf("bounds %v, want %v", dst.Rect, src.Rect)
+	}
+	if len(dst.Palette) != 3 || &dst.Palette[0] != &pal[0] {
+		t.Errorf("palette %v, want %v", dst.Palette, pal)
+	}
+}
+
+func TestDrawCtx(t *testing.T) {
+	src := gradient(64, 64)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dit := NewDither(FloydSteinberg)
+	rows := 0
+	dit.Progress = func(done, total int) {
+		rows++
+		if rows == 3 {
+			cancel()
+		}
+	}
+	err := dit.DrawCtx(ctx, image.NewPaletted(src.Rect, blackWhite), src.Rect, src)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+	if rows > 4 {
+		t.Errorf("%d rows drawn, canceled after 3", rows)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	src := gradient(64, 16)
+	dit := NewDither(FloydSteinberg)
+	var calls [][2]int
+	dit.Progress = func(done, total int) { calls = append(calls, [2]int{done, total}) }
+	dit.Draw(image.NewPaletted(src.Rect, blackWhite), src.Rect, src)
+	if len(calls) != 16 {
+		t.Fatalf("%d calls, want one per row", len(calls))
+	}
+	prev := 0
+	for i, c := range calls {
+		if c[1] != 64*16 || c[0] <= prev {
+			t.Fatalf("call %d is %v after %d pixels", i, c, prev)
+		}
+		prev = c[0]
+	}
+	if last := calls[len(calls)-1]; last[0] != last[1] {
+		t.Errorf("last call %v, want done == total", last)
+	}
+}
+
+// poster returns a size x size image of flat color bands
+func poster(size int) *image.RGBA {
+	bands := []color.RGBA{{200, 40, 40, 255}, {240, 220, 180, 255}, {30, 60, 120, 255}, {90, 160, 70, 255}}
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.SetRGBA(x, y, bands[(x+y)*len(bands)/(2*size)])
+		}
+	}
+	return img
+}
+
+func TestCache(t *testing.T) {
+	src := poster(64)
+	for _, pal := range []color.Palette{blackWhite, C64Palette, ANSI256Palette} {
+		dit := NewDither(FloydSteinberg)
+		want := image.NewPaletted(src.Rect, pal)
+		dit.Draw(want, src.Rect, src)
+		dit.Cache = true
+		got := image.NewPaletted(src.Rect, pal)
+		dit.Draw(got, src.Rect, src)
+		if !bytes.Equal(got.Pix, want.Pix) {
+			t.Errorf("%d colors: cached output differs", len(pal))
+		}
+	}
+}
+
+func BenchmarkCache(b *testing.B) {
+	src := poster(512)
+	for _, cache := range []bool{false, true} {
+		b.Run(fmt.Sprintf("cache=%v", cache), func(b *testing.B) {
+			dit := NewDither(FloydSteinberg)
+			dit.Cache = cache
+			dst := image.NewPaletted(src.Rect, ANSI256Palette)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				dit.Draw(dst, src.Rect, src)
+			}
+		})
+	}
+}
+
+// cube returns the palette of levels^3 evenly spaced colors
+func cube(levels int) color.Palette {
+	var pal color.Palette
+	for r := 0; r < levels; r++ {
+		for g := 0; g < levels; g++ {
+			for b := 0; b < levels; b++ {
+				pal = append(pal, color.RGBA{uint8(r * 255 / (levels - 1)), uint8(g * 255 / (levels - 1)), uint8(b * 255 / (levels - 1)), 255})
+			}
+		}
+	}
+	return pal
+}
+
+// decodingSearch finds the closest color by decoding the palette at every
+// call, like matching did before the palette was precomputed
+func decodingSearch(pal color.Palette, pixR, pixG, pixB int16) (int, uint32) {
+	var index int
+	var minDiff uint32 = 1<<32 - 1
+	for i, col := range pal {
+		r, g, b, _ := col.RGBA()
+		distance := uint32(abs(pixR-int16(r>>8)) + abs(pixG-int16(g>>8)) + abs(pixB-int16(b>>8)))
+		if distance < minDiff {
+			index = i
+			minDiff = distance
+		}
+	}
+	return index, minDiff
+}
+
+func TestPrecomputedPalette(t *testing.T) {
+	pal := cube(4)
+	m := newMatcher(pal, nil)
+	m.tree = nil
+	for r := int16(0); r < 256; r += 15 {
+		for g := int16(0); g < 256; g += 15 {
+			for b := int16(0); b < 256; b += 15 {
+				i, d := m.search(r, g, b)
+				j, e := decodingSearch(pal, r, g, b)
+				if i != j || d != e {
+					t.Fatalf("(%d, %d, %d) gives %d at %d, decoding the palette %d at %d", r, g, b, i, d, j, e)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkPrecomputedPalette(b *testing.B) {
+	pal := cube(4)
+	m := newMatcher(pal, nil)
+	m.tree = nil
+	b.Run("decoding", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			v := int16(i % 256)
+			decodingSearch(pal, v, 255-v, v/2)
+		}
+	})
+	b.Run("precomputed", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			v := int16(i % 256)
+			m.search(v, 255-v, v/2)
+		}
+	})
+}
+
+func TestDrawReusing(t *testing.T) {
+	first, second := colorful(32), poster(32)
+	dit := NewDither(FloydSteinberg)
+	want := image.NewPaletted(second.Rect, C64Palette)
+	dit.Draw(want, second.Rect, second)
+
+	buf := NewErrorImage(first.Rect)
+	for i, src := range []image.Image{first, second} {
+		got := image.NewPaletted(src.Bounds(), C64Palette)
+		if err := dit.DrawReusing(buf, got, src.Bounds(), src); err != nil {
+			t.Fatal(err)
+		}
+		if i == 1 && !bytes.Equal(got.Pix, want.Pix) {
+			t.Error("the error of the first frame bled into the second")
+		}
+	}
+}
+
+func TestDiffusionStrength(t *testing.T) {
+	src := gradient(64, 4)
+	pal := color.Palette{color.Black, color.Gray{100}, color.White}
+	want := image.NewPaletted(src.Rect, pal)
+	NewThresholdDither().Draw(want, src.Rect, src)
+	for _, matrix := range [][][]float32{FloydSteinberg, Atkinson, Stucki} {
+		dit := NewDither(matrix)
+		dit.DiffusionStrength = 0
+		got := image.NewPaletted(src.Rect, pal)
+		dit.Draw(got, src.Rect, src)
+		if !bytes.Equal(got.Pix, want.Pix) {
+			t.Error("strength 0 differs from thresholding")
+		}
+		dit.DiffusionStrength = 1
+		dit.Draw(got, src.Rect, src)
+		if bytes.Equal(got.Pix, want.Pix) {
+			t.Error("strength 1 gives plain thresholding")
+		}
+	}
+}
+
+func TestBorderPolicy(t *testing.T) {
+	r := image.Rect(0, 0, 2, 2)
+	shift := findShift(FloydSteinberg)
+	// the part of the error of each pixel received by the image
+	received := func(policy BorderPolicy, x, y int) float32 {
+		var sum float32
+		for i, row := range FloydSteinberg {
+			for j, w := range row {
+				if i == 0 && j+shift == 0 {
+					continue
+				}
+				if _, _, ok := policy.target(r, x, y, 1, x+j+shift, y+i); ok {
+					sum += w
+				}
+			}
+		}
+		return sum
+	}
+	var discard, clamp float32
+	// the last pixel has nowhere to send its error
+	for _, p := range []image.Point{{0, 0}, {1, 0}, {0, 1}} {
+		discard += received(Discard, p.X, p.Y)
+		clamp += received(Clamp, p.X, p.Y)
+	}
+	if clamp != 3 {
+		t.Errorf("Clamp keeps %v of the error of 3 pixels, want all of it", clamp)
+	}
+	if discard >= 3 {
+		t.Errorf("Discard keeps %v of the error of 3 pixels, want less", discard)
+	}
+}
+
+func TestCMYKPalette(t *testing.T) {
+	// a rich black does not survive a round trip through RGB
+	pal := color.Palette{
+		color.CMYK{0, 0, 0, 0}, color.CMYK{255, 0, 0, 0}, color.CMYK{0, 255, 0, 0},
+		color.CMYK{0, 0, 255, 0}, color.CMYK{60, 40, 40, 255}}
+	src := colorful(32)
+	dst := image.NewCMYK(src.Rect)
+	if err := NewDither(FloydSteinberg).DrawWithPalette(dst, src.Rect, src, pal); err != nil {
+		t.Fatal(err)
+	}
+	// the destination receives the exact process colors
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			c := dst.CMYKAt(x, y)
+			found := false
+			for _, p := range pal {
+				found = found || c == p
+			}
+			if !found {
+				t.Fatalf("pixel (%d, %d) is %v, not a palette color", x, y, c)
+			}
+		}
+	}
+
+	// flat process colors are matched exactly
+	for i, p := range pal {
+		dst := image.NewCMYK(image.Rect(0, 0, 4, 4))
+		NewDither(FloydSteinberg).DrawWithPalette(dst, dst.Rect, image.NewUniform(opaqueRGBA(p)), pal)
+		if c := dst.CMYKAt(3, 3); c != p {
+			t.Errorf("process color %d gives %v, want %v", i, c, p)
+		}
+	}
+}
+
+// generic hides the concrete type of an image, its pixels are read with At
+type generic struct{ image.Image }
+
+// ycbcr converts src to an *image.YCbCr, like a decoded JPEG
+func ycbcr(src image.Image, ratio image.YCbCrSubsampleRatio) *image.YCbCr {
+	r := src.Bounds()
+	img := image.NewYCbCr(r, ratio)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := color.YCbCrModel.Convert(src.At(x, y)).(color.YCbCr)
+			img.Y[img.YOffset(x, y)] = c.Y
+			img.Cb[img.COffset(x, y)] = c.Cb
+			img.Cr[img.COffset(x, y)] = c.Cr
+		}
+	}
+	return img
+}
+
+func TestYCbCrSource(t *testing.T) {
+	for _, ratio := range []image.YCbCrSubsampleRatio{image.YCbCrSubsampleRatio444, image.YCbCrSubsampleRatio422, image.YCbCrSubsampleRatio420} {
+		src := ycbcr(colorful(33), ratio)
+		got, want := image.NewPaletted(src.Rect, C64Palette), image.NewPaletted(src.Rect, C64Palette)
+		NewDither(FloydSteinberg).Draw(got, src.Rect, src)
+		NewDither(FloydSteinberg).Draw(want, src.Rect, generic{src})
+		if !bytes.Equal(got.Pix, want.Pix) {
+			t.Errorf("%v: the planes give another output than At", ratio)
+		}
+	}
+}
+
+func BenchmarkYCbCrSource(b *testing.B) {
+	// a 1080p JPEG
+	src := image.NewYCbCr(image.Rect(0, 0, 1920, 1080), image.YCbCrSubsampleRatio420)
+	for i := range src.Y {
+		src.Y[i] = uint8(i)
+	}
+	for i := range src.Cb {
+		src.Cb[i], src.Cr[i] = uint8(i/7), uint8(i/3)
+	}
+	dst := image.NewPaletted(src.Rect, C64Palette)
+	for name, img := range map[string]image.Image{"planes": src, "at": generic{src}} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				NewDither(FloydSteinberg).Draw(dst, src.Rect, img)
+			}
+		})
+	}
+}
+
+func TestInvert(t *testing.T) {
+	src := colorful(32)
+	dit := NewDither(FloydSteinberg)
+	want := image.NewPaletted(src.Rect, blackWhite)
+	dit.Draw(want, src.Rect, src)
+	dit.Invert = true
+	got := image.NewPaletted(src.Rect, blackWhite)
+	dit.Draw(got, src.Rect, src)
+	for i := range got.Pix {
+		if got.Pix[i] != 1-want.Pix[i] {
+			t.Fatalf("pixel %d is %d, want the negative of %d", i, got.Pix[i], want.Pix[i])
+		}
+	}
+
+	// every color is replaced with the farthest one of the palette
+	pal := color.Palette{color.Black, color.Gray{100}, color.White, color.RGBA{255, 0, 0, 255}}
+	if far, want := farthestColors(pal), []int{2, 2, 0, 2}; fmt.Sprint(far) != fmt.Sprint(want) {
+		t.Errorf("farthest colors %v, want %v", far, want)
+	}
+}
+
+func TestEdgeThreshold(t *testing.T) {
+	// a dark half at 90 and a light half at 170
+	src := image.NewGray(image.Rect(0, 0, 32, 256))
+	for i := range src.Pix {
+		src.Pix[i] = 90
+		if i%32 >= 16 {
+			src.Pix[i] = 170
+		}
+	}
+	// how far the white pixels of the last dark column are from the dark interior
+	bleeding := func(threshold float32) int {
+		dit := NewDither(FloydSteinberg)
+		dit.EdgeThreshold = threshold
+		dst := image.NewPaletted(src.Rect, blackWhite)
+		dit.Draw(dst, src.Rect, src)
+		var columns [32]int
+		for i, index := range dst.Pix {
+			columns[i%32] += int(index)
+		}
+		d := columns[15] - columns[8]
+		if d < 0 {
+			d = -d
+		}
+		return d
+	}
+	if plain, edges := bleeding(0), bleeding(16); edges > 4 || edges >= plain {
+		t.Errorf("the edge column is off by %d white pixels with EdgeThreshold, %d without", edges, plain)
+	}
+}
+
+func TestDrawSubRect(t *testing.T) {
+	src := colorful(32)
+	rect := image.Rect(8, 8, 24, 24)
+	for name, alg := range algorithms() {
+		dst := image.NewPaletted(src.Rect, C64Palette)
+		for i := range dst.Pix {
+			dst.Pix[i] = 7
+		}
+		if err := alg.DrawE(dst, rect, src); err != nil {
+			t.Fatal(err)
+		}
+		drawn := false
+		for y := 0; y < 32; y++ {
+			for x := 0; x < 32; x++ {
+				index := dst.ColorIndexAt(x, y)
+				if !image.Pt(x, y).In(rect) && index != 7 {
+					t.Fatalf("%s: pixel (%d, %d) outside of %v was drawn", name, x, y, rect)
+				}
+				drawn = drawn || index != 7
+			}
+		}
+		if !drawn {
+			t.Errorf("%s: %v is not drawn", name, rect)
+		}
+	}
+}
+
+func TestConcurrentDraw(t *testing.T) {
+	src := colorful(64)
+	dit := NewDither(StevensonArce)
+	dit.Serpentine = true
+	dit.Cache = true
+	want := image.NewPaletted(src.Rect, ANSI256Palette)
+	dit.Draw(want, src.Rect, src)
+
+	// run with -race: the Dither is shared, the destinations are not
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dst := image.NewPaletted(src.Rect, ANSI256Palette)
+			dit.Draw(dst, src.Rect, src)
+			if !bytes.Equal(dst.Pix, want.Pix) {
+				t.Error("a concurrent Draw differs")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestClone(t *testing.T) {
+	dit := NewDitherAnimation(FloydSteinberg, 4)
+	clone := dit.Clone()
+	if clone.animation == dit.animation {
+		t.Fatal("the clone shares the animation channel")
+	}
+	clone.Matrix[0][2] = 0
+	if FloydSteinberg[0][2] == 0 || dit.Matrix[0][2] == 0 {
+		t.Fatal("the clone shares the matrix")
+	}
+
+	// the frames of the clone only reach the clone
+	src := gradient(16, 16)
+	go clone.Draw(image.NewPaletted(src.Rect, blackWhite), src.Rect, src)
+	if n, ok := retrieveAll(clone, time.Second); !ok || n == 0 {
+		t.Errorf("%d frames retrieved from the clone", n)
+	}
+	select {
+	case <-dit.animation:
+		t.Error("a frame of the clone reached the original")
+	default:
+	}
+}
+
+func TestString(t *testing.T) {
+	dit := NewDitherAnimation(FloydSteinberg, 3)
+	dit.Serpentine = true
+	if got, want := dit.String(), "Dither{matrix: floyd-steinberg 3x2, serpentine: true, damping: 0.75, frames: 3}"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	custom := NewDither([][]float32{{0, 0.3, 0.2}, {0.1, 0.2, 0.1}, {0.05, 0.05, 0}})
+	if got, want := custom.String(), "Dither{matrix: custom 3x3, serpentine: false, damping: 0.75, frames: 1}"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestDitherRange(t *testing.T) {
+	src := gradient(256, 16)
+	dit := NewDither(FloydSteinberg)
+	dit.DitherRange = [2]float32{0.25, 0.75}
+	dst := image.NewPaletted(src.Rect, blackWhite)
+	dit.Draw(dst, src.Rect, src)
+	for x := 0; x < 256; x++ {
+		white := 0
+		for y := 0; y < 16; y++ {
+			white += int(dst.ColorIndexAt(x, y))
+		}
+		switch level := float32(src.GrayAt(x, 0).Y) / 255; {
+		case level < 0.25 && white != 0:
+			t.Fatalf("column %d in the shadows has %d white pixels", x, white)
+		case level > 0.75 && white != 16:
+			t.Fatalf("column %d in the highlights has %d black pixels", x, 16-white)
+		}
+	}
+	// the midtones are dithered
+	for y := 0; y < 16; y++ {
+		row := dst.Pix[dst.PixOffset(96, y):dst.PixOffset(160, y)]
+		if bytes.IndexByte(row, 0) < 0 || bytes.IndexByte(row, 1) < 0 {
+			t.Fatalf("row %d of the midtones is flat", y)
+		}
+	}
+}
+
+func TestDrawIndices(t *testing.T) {
+	src := colorful(40)
+	rect := image.Rect(5, 7, 33, 30)
+	pix, stride := NewDither(FloydSteinberg).DrawIndices(rect, src, C64Palette)
+	want := image.NewPaletted(rect, C64Palette)
+	NewDither(FloydSteinberg).Draw(want, rect, src)
+	if stride != want.Stride || !bytes.Equal(pix, want.Pix) {
+		t.Errorf("stride %d, want %d, or indices differ from the paletted image", stride, want.Stride)
+	}
+	if len(pix) != rect.Dx()*rect.Dy() {
+		t.Errorf("%d indices, want %d", len(pix), rect.Dx()*rect.Dy())
+	}
+}
+
+func TestTieBreak(t *testing.T) {
+	// 150 is as far from 100 as from 200, the lowest index wins
+	a, b := color.Gray{100}, color.Gray{200}
+	filler := make(color.Palette, 0, 32)
+	for i := 0; i < 30; i++ {
+		filler = append(filler, color.RGBA{255, uint8(i), 0, 255})
+	}
+	for _, pal := range []color.Palette{{a, b}, {b, a}, append(color.Palette{a, b}, filler...), append(color.Palette{b, a}, filler...)} {
+		for _, dist := range []DistanceFunc{nil, EuclideanDistance} {
+			m := newMatcher(pal, dist)
+			if index, _ := m.closest(150, 150, 150); index != 0 {
+				t.Errorf("%d colors starting with %v: index %d, want 0", len(pal), pal[0], index)
+			}
+		}
+	}
+}
+
+func TestLargePalette(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	pal := make(color.Palette, 1024)
+	for i := range pal {
+		pal[i] = color.RGBA{uint8(rnd.Intn(256)), uint8(rnd.Intn(256)), uint8(rnd.Intn(256)), 255}
+	}
+	src := colorful(64)
+	dst := image.NewRGBA(src.Rect)
+	if err := NewThresholdDither().DrawWithPalette(dst, src.Rect, src, pal); err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			c := src.RGBAAt(x, y)
+			index, _ := decodingSearch(pal, int16(c.R), int16(c.G), int16(c.B))
+			if got := dst.RGBAAt(x, y); got != pal[index] {
+				t.Fatalf("(%d, %d) is %v, want the closest color %v", x, y, got, pal[index])
+			}
+		}
+	}
+}
+
+func TestUniformSource(t *testing.T) {
+	r := image.Rect(0, 0, 48, 32)
+	src := image.NewUniform(color.NRGBA{200, 120, 40, 200})
+	for _, pal := range []color.Palette{blackWhite, C64Palette, ANSI256Palette} {
+		got, want := image.NewPaletted(r, pal), image.NewPaletted(r, pal)
+		NewDither(FloydSteinberg).Draw(got, r, src)
+		NewDither(FloydSteinberg).Draw(want, r, generic{src})
+		if !bytes.Equal(got.Pix, want.Pix) {
+			t.Errorf("%d colors: the uniform source gives another output than At", len(pal))
+		}
+	}
+}
+
+func BenchmarkUniformSource(b *testing.B) {
+	r := image.Rect(0, 0, 1024, 1024)
+	src := image.NewUniform(color.RGBA{200, 120, 40, 255})
+	dst := image.NewPaletted(r, C64Palette)
+	for name, img := range map[string]image.Image{"uniform": src, "at": generic{src}} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				NewDither(FloydSteinberg).Draw(dst, r, img)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package dithering
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// KnuthClassMatrix is the 8x8 class matrix of Knuth's dot diffusion
+var KnuthClassMatrix = [][]int{
+	{34, 48, 40, 32, 29, 15, 23, 31},
+	{42, 58, 56, 53, 21, 5, 7, 10},
+	{50, 62, 61, 45, 13, 1, 2, 18},
+	{38, 46, 54, 37, 25, 17, 9, 26},
+	{28, 14, 22, 30, 35, 49, 41, 33},
+	{20, 4, 6, 11, 43, 59, 57, 52},
+	{12, 0, 3, 19, 51, 63, 60, 44},
+	{24, 16, 8, 27, 36, 47, 55, 39}}
+
+// ErrClassMatrix is returned when a class matrix is not a square matrix
+// holding every class from 0 to size*size-1 once
+var ErrClassMatrix = errors.New("dithering: malformed class matrix")
+
+// DotDiffusion represent Knuth's dot diffusion algorithm implementation
+//
+// Pixels are processed class by class following a class matrix tiled over
+// the image, the error is diffused to the neighbors of higher classes only
+type DotDiffusion struct {
+	// ClassMatrix is a square matrix holding every class from 0 to size*size-1
+	ClassMatrix [][]int
+}
+
+// NewDotDiffusion prepares a dot diffusion algorithm using KnuthClassMatrix
+func NewDotDiffusion() DotDiffusion {
+	return DotDiffusion{KnuthClassMatrix}
+}
+
+// Size returns the size of the class matrix
+func (dit DotDiffusion) Size() int {
+	return len(dit.ClassMatrix)
+}
+
+// validate checks that the class matrix is square and holds every class once
+func (dit DotDiffusion) validate() error {
+	n := dit.Size()
+	seen := make([]bool, n*n)
+	for i, row := range dit.ClassMatrix {
+		if len(row) != n {
+			return fmt.Errorf("%w: row %d has %d classes, expected %d", ErrClassMatrix, i, len(row), n)
+		}
+		for j, c := range row {
+			if c < 0 || c >= n*n || seen[c] {
+				return fmt.Errorf("%w: class %d at (%d, %d)", ErrClassMatrix, c, j, i)
+			}
+			seen[c] = true
+		}
+	}
+	return nil
+}
+
+// class returns the class of the pixel at (x, y) relative to rect
+func (dit DotDiffusion) class(rect image.Rectangle, x, y int) int {
+	n := dit.Size()
+	return dit.ClassMatrix[(y-rect.Min.Y)%n][(x-rect.Min.X)%n]
+}
+
+// processingOrder lists the pixels of rect grouped by increasing class
+func (dit DotDiffusion) processingOrder(rect image.Rectangle) []image.Point {
+	n := dit.Size()
+	classes := make([][]image.Point, n*n)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			c := dit.class(rect, x, y)
+			classes[c] = append(classes[c], image.Point{x, y})
+		}
+	}
+	order := make([]image.Point, 0, rect.Dx()*rect.Dy())
+	for _, points := range classes {
+		order = append(order, points...)
+	}
+	return order
+}
+
+// Draw applies a dot diffusion algorithm to the src image
+//
+// Errors are ignored, use DrawE to retrieve them
+func (dit DotDiffusion) Draw(dst draw.Image, rect image.Rectangle, src image.Image) {
+	_ = dit.DrawE(dst, rect, src)
+}
+
+// DrawE applies a dot diffusion algorithm to the src image
+//
+// It returns an error if the destination is not paletted, if its palette is
+// empty or if the class matrix is malformed
+func (dit DotDiffusion) DrawE(dst draw.Image, rect image.Rectangle, src image.Image) error {
+	return drawE(dst, rect, src, dit.DrawWithPalette)
+}
+
+// DrawWithPalette applies a dot diffusion algorithm to the src image
+// using the given palette, like Dither.DrawWithPalette
+//
+// It returns an error if the palette is empty or if the class matrix is malformed
+func (dit DotDiffusion) DrawWithPalette(dst draw.Image, rect image.Rectangle, src image.Image, pal color.Palette) error {
+	if len(pal) == 0 {
+		return ErrEmptyPalette
+	}
+	if dit.Size() == 0 {
+		dit.ClassMatrix = KnuthClassMatrix
+	}
+	if err := dit.validate(); err != nil {
+		return err
+	}
+	m := newMatcher(pal, nil)
+	err := NewErrorImage(rect)
+	out := newPaletteWriter(dst, pal)
+	for _, pt := range dit.processingOrder(rect) {
+		i, e, _ := findColor(err.PixelErrorAt(pt.X, pt.Y), src.At(pt.X, pt.Y), m, 1)
+		out.set(pt.X, pt.Y, i)
+
+		// orthogonal neighbors weigh twice as much as diagonal ones
+		class := dit.class(rect, pt.X, pt.Y)
+		var total float32
+		var weights [3][3]float32
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				nx, ny := pt.X+dx, pt.Y+dy
+				if (dx == 0 && dy == 0) || !(image.Point{nx, ny}.In(rect)) || dit.class(rect, nx, ny) <= class {
+					continue
+				}
+				w := float32(1)
+				if dx == 0 || dy == 0 {
+					w = 2
+				}
+				weights[dy+1][dx+1] = w
+				total += w
+			}
+		}
+		if total == 0 {
+			continue
+		}
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				if w := weights[dy+1][dx+1]; w > 0 {
+					nx, ny := pt.X+dx, pt.Y+dy
+					err.SetPixelError(nx, ny, err.PixelErrorAt(nx, ny).Add(e.Mul(w/total)))
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package dithering
+
+import (
+	"errors"
+	"image"
+	"testing"
+)
+
+func TestDotDiffusionOrder(t *testing.T) {
+	// Knuth's map and a 4x4 one on rects not multiple of their size
+	small := DotDiffusion{[][]int{{0, 8, 2, 10}, {12, 4, 14, 6}, {3, 11, 1, 9}, {15, 7, 13, 5}}}
+	for _, dit := range []DotDiffusion{NewDotDiffusion(), small} {
+		for _, r := range []image.Rectangle{image.Rect(0, 0, 8, 8), image.Rect(3, -2, 20, 11), image.Rect(0, 0, 1, 5)} {
+			order := dit.processingOrder(r)
+			if len(order) != r.Dx()*r.Dy() {
+				t.Errorf("%d: %v: %d pixels processed, want %d", dit.Size(), r, len(order), r.Dx()*r.Dy())
+				continue
+			}
+			seen := make(map[image.Point]bool)
+			prev := 0
+			for _, p := range order {
+				c := dit.class(r, p.X, p.Y)
+				if !p.In(r) || seen[p] || c < prev {
+					t.Errorf("%d: %v: %v of class %d outside of rect, processed twice or after class %d", dit.Size(), r, p, c, prev)
+					break
+				}
+				seen[p] = true
+				prev = c
+			}
+		}
+	}
+}
+
+func TestClassMatrix(t *testing.T) {
+	src := gradient(8, 8)
+	for name, classes := range map[string][][]int{
+		"too large": {{0, 1}, {2, 4}},
+		"negative":  {{0, 1}, {-1, 3}},
+		"repeated":  {{0, 1}, {1, 3}},
+		"ragged":    {{0, 1, 2}, {3}},
+	} {
+		dst := image.NewPaletted(src.Rect, blackWhite)
+		if err := (DotDiffusion{classes}).DrawE(dst, dst.Rect, src); !errors.Is(err, ErrClassMatrix) {
+			t.Errorf("%s: got %v, want %v", name, err, ErrClassMatrix)
+		}
+	}
+	dst := image.NewPaletted(src.Rect, blackWhite)
+	if err := (DotDiffusion{[][]int{{3, 1}, {0, 2}}}).DrawE(dst, dst.Rect, src); err != nil {
+		t.Errorf("valid matrix: %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package dithering
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// translatedImage exposes an image whose pixels are shifted by an offset
+//
+// The pixel at (x, y) is the pixel of the underlying image at (x, y) + offset
+type translatedImage struct {
+	image.Image
+	offset image.Point
+}
+
+// Bounds returns the domain for which At can return non-zero color
+func (t translatedImage) Bounds() image.Rectangle {
+	return t.Image.Bounds().Sub(t.offset)
+}
+
+// At returns the color of the pixel at (x, y)
+func (t translatedImage) At(x, y int) color.Color {
+	return t.Image.At(x+t.offset.X, y+t.offset.Y)
+}
+
+// translate shifts src so that sp is aligned with r.Min
+func translate(src image.Image, r image.Rectangle, sp image.Point) image.Image {
+	offset := sp.Sub(r.Min)
+	if offset == (image.Point{}) {
+		return src
+	}
+	return translatedImage{src, offset}
+}
+
+// Drawer adapts a Dither to the draw.Drawer interface
+type Drawer struct {
+	Dither Dither
+}
+
+// Draw applies the error diffusion algorithm to the r rectangle of dst,
+// sampling src from sp like draw.Draw
+func (d Drawer) Draw(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point) {
+	d.Dither.Draw(dst, r, translate(src, r, sp))
+}
+
+// Drawer returns a draw.Drawer applying the dithering algorithm
+func (dit Dither) Drawer() draw.Drawer {
+	return Drawer{dit}
+}
+
+// DrawAt applies an error diffusion algorithm to the rect rectangle of dst,
+// sampling the region of src starting at sp
+//
+// It returns an error if the destination is not paletted or if its palette is empty
+func (dit Dither) DrawAt(dst draw.Image, rect image.Rectangle, src image.Image, sp image.Point) error {
+	return dit.DrawE(dst, rect, translate(src, rect, sp))
+}
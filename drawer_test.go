@@ -0,0 +1,48 @@
+package dithering
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"testing"
+)
+
+func TestDrawer(t *testing.T) {
+	src := gradient(64, 8)
+	r := image.Rect(0, 0, 16, 8)
+	sp := image.Pt(32, 0)
+	var drawer draw.Drawer = NewDither(FloydSteinberg).Drawer()
+	got := image.NewPaletted(r, blackWhite)
+	drawer.Draw(got, r, src, sp)
+
+	// the same region copied at the origin
+	region := image.NewGray(r)
+	draw.Draw(region, r, src, sp, draw.Src)
+	want := image.NewPaletted(r, blackWhite)
+	NewDither(FloydSteinberg).Draw(want, r, region)
+	if !bytes.Equal(got.Pix, want.Pix) {
+		t.Errorf("got %v, want %v", got.Pix, want.Pix)
+	}
+	if bytes.IndexByte(got.Pix, 1) < 0 {
+		t.Error("the source point is ignored, the region is black")
+	}
+}
+
+func TestDrawAt(t *testing.T) {
+	src := colorful(48)
+	rect := image.Rect(4, 4, 20, 20)
+	sp := image.Pt(10, 10)
+	got := image.NewPaletted(image.Rect(0, 0, 24, 24), C64Palette)
+	if err := NewDither(FloydSteinberg).DrawAt(got, rect, src, sp); err != nil {
+		t.Fatal(err)
+	}
+
+	// the region of src from (10, 10) copied into rect
+	region := image.NewRGBA(got.Rect)
+	draw.Draw(region, rect, src, sp, draw.Src)
+	want := image.NewPaletted(got.Rect, C64Palette)
+	NewDither(FloydSteinberg).Draw(want, rect, region)
+	if !bytes.Equal(got.Pix, want.Pix) {
+		t.Error("the region starting at sp is not sampled")
+	}
+}
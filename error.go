@@ -9,9 +9,17 @@ import (
 // PixelError represents the error for each canal in the image
 // when dithering an image
 // Errors are floats because they are the result of a division
+//
+// R, G and B are the differences between the wanted and the chosen channels,
+// in 8-bit units, or in 16-bit units with HighPrecision and LinearMatching.
+// A is not an error: matching sets it to 1<<16-1 to mark the error of a
+// processed pixel and the arithmetic methods reset it to 0.
 type PixelError struct {
+	// R, G, B are the red, green and blue errors
+	R, G, B float32
 	// TODO(brouxco): the alpha value does not make a lot of sense in a PixelError
-	R, G, B, A float32
+	// A marks the error of a processed pixel
+	A float32
 }
 
 // RGBA returns the errors for each canal in the image
@@ -35,6 +43,16 @@ func (c PixelError) Mul(v float32) PixelError {
 	return PixelError{r, g, b, 0}
 }
 
+// Scale multiplies each channel of a PixelError by its own factor
+func (c PixelError) Scale(r, g, b float32) PixelError {
+	return PixelError{c.R * r, c.G * g, c.B * b, 0}
+}
+
+// Clamp restricts each channel of a PixelError to the [min, max] range
+func (c PixelError) Clamp(min, max float32) PixelError {
+	return PixelError{clampFloat(c.R, min, max), clampFloat(c.G, min, max), clampFloat(c.B, min, max), 0}
+}
+
 func pixelErrorModel(c color.Color) color.Color {
 	if _, ok := c.(PixelError); ok {
 		return c
@@ -79,6 +97,8 @@ func (p *ErrorImage) At(x, y int) color.Color {
 }
 
 // PixelErrorAt returns the pixel error at (x, y)
+//
+// It returns a zero PixelError outside of the bounds of the image
 func (p *ErrorImage) PixelErrorAt(x, y int) PixelError {
 	if !(image.Point{x, y}.In(p.Rect)) {
 		return PixelError{}
@@ -131,6 +151,8 @@ func (p *ErrorImage) Set(x, y int, c color.Color) {
 }
 
 // SetPixelError sets the error of the pixel at (x, y)
+//
+// It does nothing outside of the bounds of the image
 func (p *ErrorImage) SetPixelError(x, y int, c PixelError) {
 	if !(image.Point{x, y}.In(p.Rect)) {
 		return
@@ -160,9 +182,62 @@ func (p *ErrorImage) SetPixelError(x, y int, c PixelError) {
 	p.Pix[i+3] = c.A
 }
 
-// NewErrorImage returns a new ErrorImage image with the given width and height
+// setPixelError sets the error of the pixel at (x, y), the Min and Max of the
+// image are only updated when track is set
+func (p *ErrorImage) setPixelError(x, y int, c PixelError, track bool) {
+	if track {
+		p.SetPixelError(x, y, c)
+		return
+	}
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+	i := p.PixOffset(x, y)
+	p.Pix[i+0] = c.R
+	p.Pix[i+1] = c.G
+	p.Pix[i+2] = c.B
+	p.Pix[i+3] = c.A
+}
+
+// updateBounds sets the Min and Max of the image from its current pixels
+func (p *ErrorImage) updateBounds() {
+	p.Min, p.Max = PixelError{}, PixelError{}
+	for i := 0; i+3 < len(p.Pix); i += 4 {
+		p.Min.R = float32(math.Min(float64(p.Min.R), float64(p.Pix[i+0])))
+		p.Min.G = float32(math.Min(float64(p.Min.G), float64(p.Pix[i+1])))
+		p.Min.B = float32(math.Min(float64(p.Min.B), float64(p.Pix[i+2])))
+		p.Max.R = float32(math.Max(float64(p.Max.R), float64(p.Pix[i+0])))
+		p.Max.G = float32(math.Max(float64(p.Max.G), float64(p.Pix[i+1])))
+		p.Max.B = float32(math.Max(float64(p.Max.B), float64(p.Pix[i+2])))
+	}
+}
+
+// NewErrorImage returns a new ErrorImage image with the given bounds
+//
+// No padding is allocated around r: the diffusion matrices reach past the
+// bounds at the borders, reading there gives no error and writing there is
+// ignored, so any matrix is safe whatever its size.
 func NewErrorImage(r image.Rectangle) *ErrorImage {
 	w, h := r.Dx(), r.Dy()
 	buf := make([]float32, 4*w*h)
 	return &ErrorImage{buf, 4 * w, r, PixelError{}, PixelError{}}
 }
+
+// Reset clears every pixel error and sets the bounds of the image to r
+//
+// The underlying buffer is reused when it is large enough
+func (p *ErrorImage) Reset(r image.Rectangle) {
+	w, h := r.Dx(), r.Dy()
+	if cap(p.Pix) < 4*w*h {
+		p.Pix = make([]float32, 4*w*h)
+	} else {
+		p.Pix = p.Pix[:4*w*h]
+		for i := range p.Pix {
+			p.Pix[i] = 0
+		}
+	}
+	p.Stride = 4 * w
+	p.Rect = r
+	p.Min = PixelError{}
+	p.Max = PixelError{}
+}
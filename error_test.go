@@ -0,0 +1,54 @@
+package dithering
+
+import (
+	"image"
+	"testing"
+)
+
+func TestPixelError(t *testing.T) {
+	a := PixelError{1.5, -2, 4, 1<<16 - 1}
+	b := PixelError{0.5, 3, -6, 1<<16 - 1}
+	for name, c := range map[string]struct{ got, want PixelError }{
+		"Add":   {a.Add(b), PixelError{2, 1, -2, 0}},
+		"Mul":   {a.Mul(0.5), PixelError{0.75, -1, 2, 0}},
+		"Scale": {a.Scale(2, 0, -1), PixelError{3, 0, -4, 0}},
+		"Clamp": {b.Clamp(-1, 1), PixelError{0.5, 1, -1, 0}},
+	} {
+		if c.got != c.want {
+			t.Errorf("%s gives %v, want %v", name, c.got, c.want)
+		}
+	}
+	if a != (PixelError{1.5, -2, 4, 1<<16 - 1}) {
+		t.Error("the arithmetic modified the receiver")
+	}
+}
+
+func TestErrorImageBounds(t *testing.T) {
+	r := image.Rect(2, -1, 7, 3)
+	for _, name := range MatrixNames() {
+		dit, _ := DitherByName(name)
+		shift := findShift(dit.Matrix)
+		img := NewErrorImage(r)
+		e := PixelError{1, 2, 3, 1<<16 - 1}
+		// every write of the matrix from every pixel, past the borders included
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				for i, row := range dit.Matrix {
+					for j := range row {
+						for _, dir := range []int{1, -1} {
+							nx, ny := x+dir*(j+shift), y+i
+							img.SetPixelError(nx, ny, e)
+							got := img.PixelErrorAt(nx, ny)
+							if in := image.Pt(nx, ny).In(r); in && got != e || !in && got != (PixelError{}) {
+								t.Fatalf("%s: (%d, %d) reads %v", name, nx, ny, got)
+							}
+						}
+					}
+				}
+			}
+		}
+		if len(img.Pix) != 4*r.Dx()*r.Dy() {
+			t.Errorf("%s: %d values, want no padding", name, len(img.Pix))
+		}
+	}
+}
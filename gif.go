@@ -0,0 +1,116 @@
+package dithering
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+)
+
+// copyPaletted returns a copy of a paletted image sharing its palette
+func copyPaletted(p *image.Paletted) *image.Paletted {
+	pix := make([]uint8, len(p.Pix))
+	copy(pix, p.Pix)
+	return &image.Paletted{Pix: pix, Stride: p.Stride, Rect: p.Rect, Palette: p.Palette}
+}
+
+// EncodeGIF dithers src into dst and writes every generated frame as a looping animated GIF
+//
+// The delay is in 100ths of a second. A Dither prepared with NewDither
+// produces a single frame.
+func EncodeGIF(w io.Writer, dit Dither, dst *image.Paletted, src image.Image, delayPerFrame int) error {
+	if len(dst.Palette) == 0 {
+		return ErrEmptyPalette
+	}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- dit.DrawE(dst, dst.Bounds(), src)
+	}()
+
+	anim := &gif.GIF{}
+	for {
+		frame, ok := dit.RetrieveFrame()
+		if !ok {
+			break
+		}
+		anim.Image = append(anim.Image, frame.(*image.Paletted))
+		anim.Delay = append(anim.Delay, delayPerFrame)
+	}
+	if err := <-errc; err != nil {
+		return err
+	}
+	if len(anim.Image) == 0 {
+		anim.Image = append(anim.Image, copyPaletted(dst))
+		anim.Delay = append(anim.Delay, delayPerFrame)
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// DitherGIF dithers every frame of an animated GIF with the given matrix and palette
+//
+// Delays, disposal methods and loop count are kept. When the frames have a
+// transparent color and the palette has none, a transparent entry is appended
+// to the palette so that transparent pixels stay transparent.
+func DitherGIF(g *gif.GIF, matrix [][]float32, pal color.Palette) (*gif.GIF, error) {
+	return DitherGIFWith(g, NewDither(matrix), pal)
+}
+
+// DitherGIFWith dithers every frame of an animated GIF with dit and the given
+// palette, like DitherGIF
+//
+// PreserveAlpha is always set and the drawing of each frame is never animated.
+// With CarryError, every frame starts with the residual error of the previous
+// frames at the same positions of the canvas, which keeps the patterns of
+// still areas from flickering.
+func DitherGIFWith(g *gif.GIF, dit Dither, pal color.Palette) (*gif.GIF, error) {
+	if len(pal) == 0 {
+		return nil, ErrEmptyPalette
+	}
+	framePal := pal
+	if transparentIndex(pal) < 0 && len(pal) < 256 {
+		for _, frame := range g.Image {
+			if transparentIndex(frame.Palette) >= 0 {
+				framePal = append(pal[:len(pal):len(pal)], color.RGBA{})
+				break
+			}
+		}
+	}
+
+	dit.PreserveAlpha = true
+	dit.nbFrames = 1
+	var buf *ErrorImage
+	if dit.CarryError {
+		var canvas image.Rectangle
+		for _, frame := range g.Image {
+			canvas = canvas.Union(frame.Bounds())
+		}
+		buf = NewErrorImage(canvas)
+	}
+	out := &gif.GIF{
+		Delay:           append([]int(nil), g.Delay...),
+		Disposal:        append([]byte(nil), g.Disposal...),
+		LoopCount:       g.LoopCount,
+		Config:          g.Config,
+		BackgroundIndex: g.BackgroundIndex,
+	}
+	for _, frame := range g.Image {
+		dst := image.NewPaletted(frame.Bounds(), framePal)
+		var err error
+		if buf != nil {
+			err = dit.DrawReusing(buf, dst, dst.Bounds(), frame)
+		} else {
+			err = dit.DrawE(dst, dst.Bounds(), frame)
+		}
+		if err != nil {
+			return nil, err
+		}
+		out.Image = append(out.Image, dst)
+	}
+	if out.Config.ColorModel != nil {
+		out.Config.ColorModel = framePal
+	}
+	if int(out.BackgroundIndex) >= len(framePal) {
+		out.BackgroundIndex = 0
+	}
+	return out, nil
+}
@@ -0,0 +1,79 @@
+package dithering
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+// twoFrames returns a looping two frame GIF of the same gray rectangle
+func twoFrames() *gif.GIF {
+	pal := color.Palette{color.Black, color.Gray{100}, color.White}
+	g := &gif.GIF{Delay: []int{10, 20}, Disposal: []byte{gif.DisposalNone, gif.DisposalBackground}, LoopCount: 3}
+	for i := 0; i < 2; i++ {
+		frame := image.NewPaletted(image.Rect(0, 0, 16, 8), pal)
+		for j := range frame.Pix {
+			frame.Pix[j] = 1
+		}
+		g.Image = append(g.Image, frame)
+	}
+	return g
+}
+
+func TestDitherGIF(t *testing.T) {
+	g := twoFrames()
+	out, err := DitherGIF(g, FloydSteinberg, blackWhite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Image) != 2 || out.LoopCount != 3 || out.Delay[1] != 20 || out.Disposal[1] != gif.DisposalBackground {
+		t.Fatalf("metadata not kept: %d frames, loop %d, delays %v, disposal %v", len(out.Image), out.LoopCount, out.Delay, out.Disposal)
+	}
+	for i, frame := range out.Image {
+		if bytes.IndexByte(frame.Pix, 0) < 0 || bytes.IndexByte(frame.Pix, 1) < 0 {
+			t.Errorf("frame %d is not dithered", i)
+		}
+	}
+	// without carried error the identical frames are dithered identically
+	if !bytes.Equal(out.Image[0].Pix, out.Image[1].Pix) {
+		t.Error("identical frames dithered differently")
+	}
+}
+
+func TestDitherGIFCarryError(t *testing.T) {
+	dit := NewDither(FloydSteinberg)
+	dit.CarryError = true
+	out, err := DitherGIFWith(twoFrames(), dit, blackWhite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(out.Image[0].Pix, out.Image[1].Pix) {
+		t.Error("the second frame does not start with the error of the first one")
+	}
+}
+
+func TestEncodeGIF(t *testing.T) {
+	src := gradient(32, 16)
+	var buf bytes.Buffer
+	dst := image.NewPaletted(src.Rect, blackWhite)
+	if err := EncodeGIF(&buf, NewDitherAnimation(FloydSteinberg, 4), dst, src, 5); err != nil {
+		t.Fatal(err)
+	}
+	g, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Image) != 4 || g.Delay[3] != 5 {
+		t.Errorf("%d frames with delays %v, want 4 frames of 5", len(g.Image), g.Delay)
+	}
+
+	buf.Reset()
+	if err := EncodeGIF(&buf, NewDither(FloydSteinberg), dst, src, 5); err != nil {
+		t.Fatal(err)
+	}
+	if g, err := gif.DecodeAll(&buf); err != nil || len(g.Image) != 1 {
+		t.Errorf("single frame: %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package dithering
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestHalftoneDither(t *testing.T) {
+	src := image.NewUniform(color.Gray{128})
+	for _, threshold := range [][][]float32{ClusteredDot6, ClusteredDot8} {
+		n := len(threshold)
+		r := image.Rect(0, 0, 4*n, 4*n)
+		dst := image.NewPaletted(r, blackWhite)
+		NewHalftoneDither(threshold).Draw(dst, r, src)
+		// the dots repeat with the period of the map
+		for y := 0; y < r.Dy(); y++ {
+			for x := 0; x < r.Dx(); x++ {
+				if dst.ColorIndexAt(x, y) != dst.ColorIndexAt(x%n, y%n) {
+					t.Fatalf("%dx%[1]d: pixel (%d, %d) differs from the tile", n, x, y)
+				}
+			}
+		}
+		// the black pixels of a tile are a single cluster holding about half of it
+		var dot []image.Point
+		for y := 0; y < n; y++ {
+			for x := 0; x < n; x++ {
+				if dst.ColorIndexAt(x, y) == 0 {
+					dot = append(dot, image.Pt(x, y))
+				}
+			}
+		}
+		if len(dot) < n*n/2-n || len(dot) > n*n/2+n {
+			t.Errorf("%dx%[1]d: %d black pixels in a tile, want about %d", n, len(dot), n*n/2)
+		}
+		if len(dot) == 0 {
+			continue
+		}
+		seen := map[image.Point]bool{dot[0]: true}
+		queue := []image.Point{dot[0]}
+		for len(queue) > 0 {
+			p := queue[0]
+			queue = queue[1:]
+			for _, d := range []image.Point{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+				q := p.Add(d)
+				if q.In(image.Rect(0, 0, n, n)) && !seen[q] && dst.ColorIndexAt(q.X, q.Y) == 0 {
+					seen[q] = true
+					queue = append(queue, q)
+				}
+			}
+		}
+		if len(seen) != len(dot) {
+			t.Errorf("%dx%[1]d: the black pixels of a tile form several clusters", n)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package dithering
+
+import "image/color"
+
+// search16 looks for the closest color of a pixel given by its 16-bit
+// channels, returning its index and distance, the lowest index on ties
+func (m matcher) search16(pixR, pixG, pixB int32) (int, uint32) {
+	var index int
+	var minDiff uint32 = 1<<32 - 1
+
+	if m.dist != nil {
+		target := color.RGBA64{uint16(pixR), uint16(pixG), uint16(pixB), 1<<16 - 1}
+		for i, col := range m.pal {
+			if distance := m.dist(target, col); distance < minDiff {
+				index = i
+				minDiff = distance
+			}
+		}
+		return index, minDiff
+	}
+
+	for i, col := range m.rgb16 {
+		distance := uint32(abs32(pixR-col[0]) + abs32(pixG-col[1]) + abs32(pixB-col[2]))
+		if distance < minDiff {
+			index = i
+			minDiff = distance
+		}
+	}
+	return index, minDiff
+}
+
+// findRGB16 is findRGB keeping the 16 bits of the channels
+//
+// The error is expressed in 16-bit units
+func findRGB16(err PixelError, r, g, b uint32, m matcher, damping float32) (int, PixelError, uint32) {
+	pixR := int32(clampFloat(float32(r)+clampFloat(err.R*damping, -65535, 65535), 0, 65535))
+	pixG := int32(clampFloat(float32(g)+clampFloat(err.G*damping, -65535, 65535), 0, 65535))
+	pixB := int32(clampFloat(float32(b)+clampFloat(err.B*damping, -65535, 65535), 0, 65535))
+
+	index, minDiff := m.search16(pixR, pixG, pixB)
+	col := m.rgb16[index]
+
+	return index,
+		PixelError{float32(pixR - col[0]),
+			float32(pixG - col[1]),
+			float32(pixB - col[2]),
+			1<<16 - 1},
+		minDiff
+}
@@ -0,0 +1,45 @@
+package dithering
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestHighPrecision(t *testing.T) {
+	// a ramp between two 16-bit levels sharing their high byte
+	const lo, hi = 0x8000, 0x80ff
+	src := image.NewGray16(image.Rect(0, 0, 256, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 256; x++ {
+			src.SetGray16(x, y, color.Gray16{uint16(lo + x*(hi-lo)/255)})
+		}
+	}
+	pal := color.Palette{color.Gray16{lo}, color.Gray16{hi}}
+	// the share of hi of every band of 32 columns
+	density := func(dit Dither) []float64 {
+		dst := image.NewPaletted(src.Rect, pal)
+		dit.Draw(dst, src.Rect, src)
+		res := make([]float64, 256/32)
+		for i, index := range dst.Pix {
+			res[i%256/32] += float64(index) / (32 * 16)
+		}
+		return res
+	}
+
+	dit := NewDither(FloydSteinberg)
+	// undamped, the density of every band follows its level
+	dit.ErrorDamping = 1
+	for _, d := range density(dit) {
+		if d != 0 {
+			t.Fatalf("8-bit densities %v, want a single band", density(dit))
+		}
+	}
+	dit.HighPrecision = true
+	for i, d := range density(dit) {
+		if want := (float64(i) + 0.5) / 8; math.Abs(d-want) > 0.05 {
+			t.Errorf("band %d: density %.3f, want %.3f", i, d, want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package dithering
+
+import (
+	"errors"
+	"fmt"
+	"image"
+)
+
+// IntMatrix is an error diffusion matrix made of integer weights over a shared divisor
+type IntMatrix struct {
+	Weights [][]int
+	Divisor int
+}
+
+var (
+	// FloydSteinbergInt is the integer Floyd Steinberg matrix
+	FloydSteinbergInt = IntMatrix{[][]int{{0, 0, 7}, {3, 5, 1}}, 16}
+	// JarvisJudiceNinkeInt is the integer JarvisJudiceNinke matrix
+	JarvisJudiceNinkeInt = IntMatrix{[][]int{{0, 0, 0, 7, 5}, {3, 5, 7, 5, 3}, {1, 3, 5, 3, 1}}, 48}
+	// StuckiInt is the integer Stucki matrix
+	StuckiInt = IntMatrix{[][]int{{0, 0, 0, 8, 4}, {2, 4, 8, 4, 2}, {1, 2, 4, 2, 1}}, 42}
+	// AtkinsonInt is the integer Atkinson matrix
+	AtkinsonInt = IntMatrix{[][]int{{0, 0, 1, 1}, {1, 1, 1, 0}, {0, 1, 0, 0}}, 8}
+	// BurkesInt is the integer Burkes matrix
+	BurkesInt = IntMatrix{[][]int{{0, 0, 0, 8, 4}, {2, 4, 8, 4, 2}}, 32}
+	// SierraInt is the integer Sierra matrix
+	SierraInt = IntMatrix{[][]int{{0, 0, 0, 5, 3}, {2, 4, 5, 4, 2}, {0, 2, 3, 2, 0}}, 32}
+	// TwoRowSierraInt is the integer equivalent of TwoRowSierra
+	TwoRowSierraInt = IntMatrix{[][]int{{0, 0, 0, 8, 6}, {1, 2, 3, 2, 1}}, 32}
+	// SierraLiteInt is the integer SierraLite matrix
+	SierraLiteInt = IntMatrix{[][]int{{0, 0, 2}, {1, 1, 0}}, 4}
+	// StevensonArceInt is the integer StevensonArce matrix
+	StevensonArceInt = IntMatrix{[][]int{
+		{0, 0, 0, 0, 0, 32, 0},
+		{12, 0, 26, 0, 30, 0, 16},
+		{0, 12, 0, 26, 0, 12, 0},
+		{5, 0, 12, 0, 12, 0, 5}}, 200}
+	// ShiauFanInt is the integer ShiauFan matrix
+	ShiauFanInt = IntMatrix{[][]int{{0, 0, 0, 4}, {1, 1, 2, 0}}, 8}
+	// ShiauFan2Int is the integer ShiauFan2 matrix
+	ShiauFan2Int = IntMatrix{[][]int{{0, 0, 0, 0, 8}, {1, 1, 2, 4, 0}}, 16}
+)
+
+// ErrInvalidDivisor is returned when the divisor of an integer matrix is not positive
+var ErrInvalidDivisor = errors.New("dithering: matrix divisor must be positive")
+
+// validate checks that an integer matrix is well formed
+//
+// The divisor must be positive, rows must have the same length and weights
+// must be non-negative
+func (m IntMatrix) validate() error {
+	if m.Divisor <= 0 {
+		return fmt.Errorf("%w: %d", ErrInvalidDivisor, m.Divisor)
+	}
+	for i, row := range m.Weights {
+		if len(row) != len(m.Weights[0]) {
+			return fmt.Errorf("%w: row %d has %d weights, expected %d", ErrRaggedMatrix, i, len(row), len(m.Weights[0]))
+		}
+		for j, w := range row {
+			if w < 0 {
+				return fmt.Errorf("%w: %d at (%d, %d)", ErrNegativeWeight, w, j, i)
+			}
+		}
+	}
+	return validateReach(m.Float())
+}
+
+// Float returns the equivalent floating point matrix
+func (m IntMatrix) Float() [][]float32 {
+	res := make([][]float32, len(m.Weights))
+	for i, row := range m.Weights {
+		res[i] = make([]float32, len(row))
+		for j, w := range row {
+			res[i][j] = float32(w) / float32(m.Divisor)
+		}
+	}
+	return res
+}
+
+// NewDitherInt prepares a dithering algorithm diffusing the error with integer arithmetic
+//
+// The output is close to the one of the equivalent floating point matrix
+// but avoids float conversions and rounding.
+// NewDitherInt panics if the matrix is not valid, NewDitherInts returns the error instead
+func NewDitherInt(m IntMatrix) Dither {
+	if err := m.validate(); err != nil {
+		panic(err)
+	}
+	dit := NewDither(m.Float())
+	dit.IntMatrix = &m
+	return dit
+}
+
+// dampingBits is the number of fractional bits of the fixed point damping
+const dampingBits = 8
+
+// intDiffusion stores the error diffused with an integer matrix
+//
+// The error of each pixel is kept multiplied by the divisor of the matrix
+type intDiffusion struct {
+	matrix IntMatrix
+	shift  int
+	// center is the weight of the current pixel
+	center  float32
+	damping int32
+	border  BorderPolicy
+	rect    image.Rectangle
+	acc     []int32
+}
+
+// newIntDiffusion prepares the diffusion of the error over the rect rectangle
+func newIntDiffusion(m IntMatrix, rect image.Rectangle, damping float32, border BorderPolicy) *intDiffusion {
+	if m.Divisor == 0 {
+		m.Divisor = 1
+	}
+	shift := findShift(m.Float())
+	return &intDiffusion{
+		matrix:  m,
+		shift:   shift,
+		center:  centerWeight(m.Float(), shift),
+		damping: int32(damping*(1<<dampingBits) + 0.5),
+		border:  border,
+		rect:    rect,
+		acc:     make([]int32, 3*rect.Dx()*rect.Dy()),
+	}
+}
+
+// offset returns the index of the first channel of the pixel at (x, y) or -1 when out of bounds
+func (d *intDiffusion) offset(x, y int) int {
+	if !(image.Point{x, y}.In(d.rect)) {
+		return -1
+	}
+	return 3 * ((y-d.rect.Min.Y)*d.rect.Dx() + (x - d.rect.Min.X))
+}
+
+// carried returns the damped error carried by the pixel at (x, y)
+func (d *intDiffusion) carried(x, y int) (r, g, b int16) {
+	i := d.offset(x, y)
+	if i < 0 {
+		return 0, 0, 0
+	}
+	div := int32(d.matrix.Divisor) << dampingBits
+	ch := func(v int32) int16 {
+		v = v * d.damping / div
+		if v < -255 {
+			return -255
+		}
+		if v > 255 {
+			return 255
+		}
+		return int16(v)
+	}
+	return ch(d.acc[i]), ch(d.acc[i+1]), ch(d.acc[i+2])
+}
+
+// diffuse spreads the residual error of the pixel at (x, y) to its neighbors
+func (d *intDiffusion) diffuse(x, y, dir int, e PixelError) {
+	r, g, b := int32(e.R), int32(e.G), int32(e.B)
+	for i, row := range d.matrix.Weights {
+		for j, w := range row {
+			if w == 0 || (i == 0 && j+d.shift == 0) {
+				continue
+			}
+			nx, ny, ok := d.border.target(d.rect, x, y, dir, x+dir*(j+d.shift), y+i)
+			if !ok {
+				continue
+			}
+			k := d.offset(nx, ny)
+			d.acc[k] += r * int32(w)
+			d.acc[k+1] += g * int32(w)
+			d.acc[k+2] += b * int32(w)
+		}
+	}
+}
+
+// NewDitherInts prepares a dithering algorithm from integer weights over a divisor,
+// like the published form of most matrices
+//
+// The error is diffused with integer arithmetic like with NewDitherInt.
+// It returns an error if the divisor is not positive, if the rows have
+// different lengths, if a weight is negative or if the matrix reaches more
+// than MaxMatrixRows rows
+func NewDitherInts(weights [][]int, divisor int) (Dither, error) {
+	m := IntMatrix{weights, divisor}
+	if err := m.validate(); err != nil {
+		return Dither{}, err
+	}
+	return NewDitherInt(m), nil
+}
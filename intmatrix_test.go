@@ -0,0 +1,88 @@
+package dithering
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"testing"
+)
+
+func TestNewDitherInts(t *testing.T) {
+	for name, c := range map[string]struct {
+		weights [][]int
+		divisor int
+		want    error
+	}{
+		"zero divisor":    {[][]int{{0, 0, 7}, {3, 5, 1}}, 0, ErrInvalidDivisor},
+		"negative weight": {[][]int{{0, 0, 7}, {3, -5, 1}}, 16, ErrNegativeWeight},
+		"ragged":          {[][]int{{0, 0, 7}, {3, 5}}, 16, ErrRaggedMatrix},
+	} {
+		if _, err := NewDitherInts(c.weights, c.divisor); !errors.Is(err, c.want) {
+			t.Errorf("%s: got %v, want %v", name, err, c.want)
+		}
+	}
+
+	dit, err := NewDitherInts(FloydSteinbergInt.Weights, FloydSteinbergInt.Divisor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := gradient(64, 16)
+	r := src.Bounds()
+	got, want := image.NewPaletted(r, blackWhite), image.NewPaletted(r, blackWhite)
+	dit.Draw(got, r, src)
+	NewDitherInt(FloydSteinbergInt).Draw(want, r, src)
+	if !bytes.Equal(got.Pix, want.Pix) {
+		t.Error("NewDitherInts differs from NewDitherInt")
+	}
+}
+
+func TestNewDitherIntPanics(t *testing.T) {
+	defer func() {
+		if err, _ := recover().(error); !errors.Is(err, ErrInvalidDivisor) {
+			t.Errorf("recovered %v, want %v", err, ErrInvalidDivisor)
+		}
+	}()
+	NewDitherInt(IntMatrix{FloydSteinbergInt.Weights, 0})
+}
+
+func TestIntMatrixFloat(t *testing.T) {
+	src := gradient(256, 32)
+	r := src.Bounds()
+	pal := GrayPalette(16)
+	for name, m := range map[string]IntMatrix{"FloydSteinberg": FloydSteinbergInt, "JarvisJudiceNinke": JarvisJudiceNinkeInt, "Atkinson": AtkinsonInt, "Sierra": SierraInt} {
+		got, want := image.NewPaletted(r, pal), image.NewPaletted(r, pal)
+		NewDitherInt(m).Draw(got, r, src)
+		NewDither(m.Float()).Draw(want, r, src)
+		for i := range got.Pix {
+			if d := int(got.Pix[i]) - int(want.Pix[i]); d < -1 || d > 1 {
+				t.Errorf("%s: pixel %d is %d, %d with the float matrix", name, i, got.Pix[i], want.Pix[i])
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkIntMatrix(b *testing.B) {
+	src := colorful(512)
+	r := src.Bounds()
+	dst := image.NewPaletted(r, C64Palette)
+	for name, dit := range map[string]Dither{"float": NewDither(FloydSteinberg), "int": NewDitherInt(FloydSteinbergInt)} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				dit.Draw(dst, r, src)
+			}
+		})
+	}
+}
+
+func TestIntMatrixStucki(t *testing.T) {
+	weights := [][]int{{0, 0, 0, 8, 4}, {2, 4, 8, 4, 2}, {1, 2, 4, 2, 1}}
+	dit, err := NewDitherInts(weights, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := dit.IntMatrix.Float(); !equalMatrices(m, Stucki) {
+		t.Errorf("Stucki from integers is %v, want %v", m, Stucki)
+	}
+}
@@ -0,0 +1,76 @@
+package dithering
+
+import "sort"
+
+// kdNode is a palette color stored in the k-d tree
+type kdNode struct {
+	index       int
+	point       [3]int16
+	axis        int
+	left, right *kdNode
+}
+
+// kdTree indexes the colors of a palette in RGB space
+//
+// It finds the closest color for the sum of absolute channel differences,
+// like the linear scan of findColor, and breaks ties in favor of the lowest index.
+type kdTree struct {
+	root *kdNode
+}
+
+// newKDTree builds a k-d tree over the 8-bit channels of the palette colors
+func newKDTree(rgb [][3]int16) *kdTree {
+	nodes := make([]*kdNode, len(rgb))
+	for i, p := range rgb {
+		nodes[i] = &kdNode{index: i, point: p}
+	}
+	return &kdTree{buildKD(nodes, 0)}
+}
+
+// buildKD recursively splits the nodes at the median of the axis
+func buildKD(nodes []*kdNode, depth int) *kdNode {
+	if len(nodes) == 0 {
+		return nil
+	}
+	axis := depth % 3
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].point[axis] != nodes[j].point[axis] {
+			return nodes[i].point[axis] < nodes[j].point[axis]
+		}
+		return nodes[i].index < nodes[j].index
+	})
+	mid := len(nodes) / 2
+	n := nodes[mid]
+	n.axis = axis
+	n.left = buildKD(nodes[:mid], depth+1)
+	n.right = buildKD(nodes[mid+1:], depth+1)
+	return n
+}
+
+// nearest returns the index of the closest color and its distance
+func (t *kdTree) nearest(r, g, b int16) (int, uint32) {
+	best, bestDist := -1, uint32(1<<32-1)
+	target := [3]int16{r, g, b}
+	var search func(n *kdNode)
+	search = func(n *kdNode) {
+		if n == nil {
+			return
+		}
+		d := uint32(abs(r-n.point[0]) + abs(g-n.point[1]) + abs(b-n.point[2]))
+		if d < bestDist || (d == bestDist && n.index < best) {
+			best, bestDist = n.index, d
+		}
+		diff := target[n.axis] - n.point[n.axis]
+		near, far := n.left, n.right
+		if diff >= 0 {
+			near, far = n.right, n.left
+		}
+		search(near)
+		// an equally distant color may still have a lower index
+		if uint32(abs(diff)) <= bestDist {
+			search(far)
+		}
+	}
+	search(t.root)
+	return best, bestDist
+}
@@ -0,0 +1,40 @@
+package dithering
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestKDTree(t *testing.T) {
+	for _, pal := range []color.Palette{ANSI256Palette, WebSafePalette, C64Palette, NESPalette} {
+		m := newMatcher(pal, nil)
+		tree := newKDTree(m.rgb)
+		linear := m
+		linear.tree = nil
+		for r := int16(0); r < 256; r += 5 {
+			for g := int16(0); g < 256; g += 5 {
+				for b := int16(0); b < 256; b += 5 {
+					i, d := tree.nearest(r, g, b)
+					j, e := linear.search(r, g, b)
+					if i != j || d != e {
+						t.Fatalf("%d colors: (%d, %d, %d) gives %d at %d, the linear scan %d at %d", len(pal), r, g, b, i, d, j, e)
+					}
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkNearest(b *testing.B) {
+	m := newMatcher(ANSI256Palette, nil)
+	linear := m
+	linear.tree = nil
+	for name, m := range map[string]matcher{"tree": m, "linear": linear} {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				v := int16(i % 256)
+				m.search(v, 255-v, v/2)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package dithering
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+)
+
+// KMeansPalette generates a palette of at most k colors from the src image
+//
+// It is KMeansPaletteSeed with a fixed seed.
+func KMeansPalette(src image.Image, k int, iterations int) color.Palette {
+	return KMeansPaletteSeed(src, k, iterations, 1)
+}
+
+// KMeansPaletteSeed generates a palette of at most k colors from the src image
+//
+// Pixels are clustered using Lloyd's algorithm, the initial centroids are
+// distinct colors of the image picked using the seed. It stops after the
+// given number of iterations or once the centroids do not move anymore.
+func KMeansPaletteSeed(src image.Image, k int, iterations int, seed int64) color.Palette {
+	hist := histogram(src)
+	if k < 1 || len(hist) == 0 {
+		return color.Palette{}
+	}
+	if len(hist) <= k {
+		pal := make(color.Palette, len(hist))
+		for i, e := range hist {
+			pal[i] = e.c
+		}
+		return pal
+	}
+
+	rnd := rand.New(rand.NewSource(seed))
+	centroids := make([][3]float64, k)
+	for i, j := range rnd.Perm(len(hist))[:k] {
+		c := hist[j].c
+		centroids[i] = [3]float64{float64(c.R), float64(c.G), float64(c.B)}
+	}
+
+	for it := 0; it < iterations; it++ {
+		sums := make([][3]float64, k)
+		counts := make([]int, k)
+		for _, e := range hist {
+			p := [3]float64{float64(e.c.R), float64(e.c.G), float64(e.c.B)}
+			nearest, minDist := 0, -1.0
+			for i, c := range centroids {
+				dr, dg, db := p[0]-c[0], p[1]-c[1], p[2]-c[2]
+				if d := dr*dr + dg*dg + db*db; minDist < 0 || d < minDist {
+					nearest, minDist = i, d
+				}
+			}
+			for ch := 0; ch < 3; ch++ {
+				sums[nearest][ch] += p[ch] * float64(e.count)
+			}
+			counts[nearest] += e.count
+		}
+
+		converged := true
+		for i := range centroids {
+			// empty clusters keep their centroid
+			if counts[i] == 0 {
+				continue
+			}
+			for ch := 0; ch < 3; ch++ {
+				v := sums[i][ch] / float64(counts[i])
+				if v != centroids[i][ch] {
+					converged = false
+				}
+				centroids[i][ch] = v
+			}
+		}
+		if converged {
+			break
+		}
+	}
+
+	pal := make(color.Palette, k)
+	for i, c := range centroids {
+		pal[i] = color.RGBA{uint8(c[0] + 0.5), uint8(c[1] + 0.5), uint8(c[2] + 0.5), 255}
+	}
+	return pal
+}
@@ -0,0 +1,20 @@
+package dithering
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKMeansPaletteSeed(t *testing.T) {
+	src := tinted(32)
+	a := KMeansPaletteSeed(src, 8, 10, 42)
+	if len(a) != 8 || !inGamut(a) {
+		t.Fatalf("palette %v, want 8 colors of the source gamut", a)
+	}
+	if b := KMeansPaletteSeed(src, 8, 10, 42); !reflect.DeepEqual(a, b) {
+		t.Error("the same seed gives different palettes")
+	}
+	if b := KMeansPalette(src, 8, 10); !reflect.DeepEqual(b, KMeansPaletteSeed(src, 8, 10, 1)) {
+		t.Error("KMeansPalette does not use its fixed seed")
+	}
+}
@@ -0,0 +1,129 @@
+package dithering
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// minMatrixSum is the lowest accepted sum of weights, Atkinson
+	// purposely diffuses only 3/4 of the error
+	minMatrixSum = 0.5
+	// matrixSumTolerance is the accepted excess over a sum of 1
+	matrixSumTolerance = 0.01
+)
+
+var (
+	// ErrRaggedMatrix is returned when the rows of a matrix have different lengths
+	ErrRaggedMatrix = errors.New("dithering: matrix rows have different lengths")
+	// ErrNegativeWeight is returned when a matrix contains a negative weight
+	ErrNegativeWeight = errors.New("dithering: matrix contains a negative weight")
+	// ErrMatrixSum is returned when the weights of a matrix do not sum to roughly 1
+	ErrMatrixSum = errors.New("dithering: matrix weights do not sum to 1")
+	// ErrMatrixReach is returned when a matrix diffuses error to more rows than MaxMatrixRows
+	ErrMatrixReach = errors.New("dithering: matrix reaches too many rows")
+)
+
+// MaxMatrixRows is the number of rows, the current one included, a diffusion
+// matrix may send error to, deeper matrices are rejected by ValidateMatrix
+//
+// The error buffers hold the whole image so any depth would be drawn, the
+// limit catches matrices diffusing the error far from its pixel, often a
+// mistyped matrix, and can be raised for unusually deep ones.
+var MaxMatrixRows = 8
+
+// ValidateMatrix checks that a diffusion matrix is well formed
+//
+// Rows must have the same length, weights must be non-negative, their sum
+// must be close to 1 and the last row holding a weight must be within
+// MaxMatrixRows. An empty matrix is valid and diffuses no error.
+func ValidateMatrix(matrix [][]float32) error {
+	if len(matrix) == 0 {
+		return nil
+	}
+	var sum float32
+	for i, row := range matrix {
+		if len(row) != len(matrix[0]) {
+			return fmt.Errorf("%w: row %d has %d weights, expected %d", ErrRaggedMatrix, i, len(row), len(matrix[0]))
+		}
+		for j, v := range row {
+			if v < 0 {
+				return fmt.Errorf("%w: %v at (%d, %d)", ErrNegativeWeight, v, j, i)
+			}
+			sum += v
+		}
+	}
+	if sum < minMatrixSum || sum > 1+matrixSumTolerance {
+		return fmt.Errorf("%w: sum is %v", ErrMatrixSum, sum)
+	}
+	return validateReach(matrix)
+}
+
+// validateReach checks that matrix sends no error past MaxMatrixRows rows
+func validateReach(matrix [][]float32) error {
+	if down := matrixDown(matrix); down >= MaxMatrixRows {
+		return fmt.Errorf("%w: %d rows, at most %d", ErrMatrixReach, down+1, MaxMatrixRows)
+	}
+	return nil
+}
+
+// matrixDown returns the index of the last row of matrix holding a weight
+func matrixDown(matrix [][]float32) int {
+	down := 0
+	for i, row := range matrix {
+		for _, v := range row {
+			if v != 0 {
+				down = i
+				break
+			}
+		}
+	}
+	return down
+}
+
+// NewDitherChecked prepares a dithering algorithm after validating its matrix
+func NewDitherChecked(matrix [][]float32) (Dither, error) {
+	if err := ValidateMatrix(matrix); err != nil {
+		return Dither{}, err
+	}
+	return NewDither(matrix), nil
+}
+
+// NormalizeMatrix returns a copy of matrix whose weights sum to 1
+//
+// A matrix without any weight is copied as is
+func NormalizeMatrix(m [][]float32) [][]float32 {
+	var sum float32
+	for _, row := range m {
+		for _, v := range row {
+			sum += v
+		}
+	}
+	res := make([][]float32, len(m))
+	for i, row := range m {
+		res[i] = make([]float32, len(row))
+		for j, v := range row {
+			if sum != 0 {
+				v /= sum
+			}
+			res[i][j] = v
+		}
+	}
+	return res
+}
+
+// Reach returns how many rows and columns around the current pixel the
+// diffusion matrix of dit sends error to, IntMatrix being used when set
+//
+// Error is only diffused forward, so up is always 0 and the error of a pixel
+// is complete once the down rows above it are drawn. A buffer holding
+// down+1 rows and left+right extra columns is enough to dither an image row
+// by row, ValidateMatrix keeps down below MaxMatrixRows.
+func (dit Dither) Reach() (up, down, left, right int) {
+	matrix := dit.Matrix
+	if dit.IntMatrix != nil {
+		matrix = dit.IntMatrix.Float()
+	}
+	left, right = matrixReach(matrix)
+	return 0, matrixDown(matrix), left, right
+}
@@ -0,0 +1,215 @@
+package dithering
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// centerKernel is Floyd-Steinberg keeping 1/8 of the error on the current pixel
+var centerKernel = [][]float32{{0, 1.0 / 8.0, 7.0 / 16.0}, {3.0 / 16.0, 5.0 / 16.0, 1.0 / 16.0}}
+
+func TestReachCenterWeight(t *testing.T) {
+	up, down, left, right := NewDither(centerKernel).Reach()
+	if up != 0 || down != 1 || left != 1 || right != 1 {
+		t.Errorf("Reach() = %d, %d, %d, %d, want 0, 1, 1, 1", up, down, left, right)
+	}
+	if shift := findShift(centerKernel); shift != -1 {
+		t.Errorf("findShift() = %d, want -1", shift)
+	}
+}
+
+func TestCenterWeight(t *testing.T) {
+	src := gradient(64, 16)
+	r := src.Bounds()
+	mean := func(img *image.Paletted) float64 {
+		var sum int
+		for _, i := range img.Pix {
+			sum += int(i) * 255
+		}
+		return float64(sum) / float64(len(img.Pix))
+	}
+	var want int
+	for _, v := range src.Pix {
+		want += int(v)
+	}
+
+	fs := image.NewPaletted(r, blackWhite)
+	NewDither(FloydSteinberg).Draw(fs, r, src)
+	dst := image.NewPaletted(r, blackWhite)
+	dit := NewDither(centerKernel)
+	dit.ErrorDamping = 1
+	dit.Draw(dst, r, src)
+	if bytes.Equal(fs.Pix, dst.Pix) {
+		t.Error("the weight of the current pixel is ignored")
+	}
+	if d := mean(dst) - float64(want)/float64(len(src.Pix)); d < -4 || d > 4 {
+		t.Errorf("mean differs from the source by %v", d)
+	}
+}
+
+func TestValidateMatrix(t *testing.T) {
+	// the error reaches one row past MaxMatrixRows
+	deep := make([][]float32, MaxMatrixRows+1)
+	for i := range deep {
+		deep[i] = make([]float32, 3)
+	}
+	deep[0][2], deep[MaxMatrixRows][1] = 0.5, 0.5
+	for name, c := range map[string]struct {
+		matrix [][]float32
+		want   error
+	}{
+		"ragged":   {[][]float32{{0, 0, 0.5}, {0.5}}, ErrRaggedMatrix},
+		"negative": {[][]float32{{0, 0, 1.5}, {-0.5, 0, 0}}, ErrNegativeWeight},
+		"low sum":  {[][]float32{{0, 0, 0.1}, {0.1, 0.1, 0.1}}, ErrMatrixSum},
+		"high sum": {[][]float32{{0, 0, 1}, {0.5, 0, 0}}, ErrMatrixSum},
+		"deep":     {deep, ErrMatrixReach},
+	} {
+		if _, err := NewDitherChecked(c.matrix); !errors.Is(err, c.want) {
+			t.Errorf("%s: got %v, want %v", name, err, c.want)
+		}
+	}
+	for _, name := range MatrixNames() {
+		dit, _ := DitherByName(name)
+		if err := ValidateMatrix(dit.Matrix); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if err := ValidateMatrix(nil); err != nil {
+		t.Errorf("empty matrix: %v", err)
+	}
+	if err := ValidateMatrix(deep[1:]); err != nil {
+		t.Errorf("%d rows: %v", MaxMatrixRows, err)
+	}
+	ints := make([][]int, MaxMatrixRows+1)
+	for i := range ints {
+		ints[i] = make([]int, 3)
+	}
+	ints[0][2], ints[MaxMatrixRows][1] = 1, 1
+	if _, err := NewDitherInts(ints, 2); !errors.Is(err, ErrMatrixReach) {
+		t.Errorf("deep integer matrix: got %v, want %v", err, ErrMatrixReach)
+	}
+}
+
+// checkBorders fails when the error diffused by matrix from any pixel of
+// rects of various sizes lands outside of the rect, the dithering of a sub
+// rectangle of a larger image must also leave the rest of it untouched
+func checkBorders(t *testing.T, name string, matrix [][]float32) {
+	t.Helper()
+	shift := findShift(matrix)
+	for _, r := range []image.Rectangle{image.Rect(0, 0, 1, 1), image.Rect(0, 0, 2, 3), image.Rect(0, 0, 5, 1), image.Rect(-3, 4, 4, 9)} {
+		for _, policy := range []BorderPolicy{Discard, Clamp} {
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					for _, dir := range []int{1, -1} {
+						for i, row := range matrix {
+							for j := range row {
+								nx, ny, ok := policy.target(r, x, y, dir, x+dir*(j+shift), y+i)
+								if ok && !image.Pt(nx, ny).In(r) {
+									t.Fatalf("%s: %v: error of (%d, %d) diffused to (%d, %d)", name, r, x, y, nx, ny)
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	src := gradient(16, 16)
+	dst := image.NewPaletted(src.Rect, color.Palette{color.Black, color.White, color.Gray{128}})
+	for i := range dst.Pix {
+		dst.Pix[i] = 2
+	}
+	rect := image.Rect(3, 3, 13, 13)
+	for _, policy := range []BorderPolicy{Discard, Clamp} {
+		dit := NewDither(matrix)
+		dit.Border = policy
+		dit.Draw(dst, rect, src)
+		for y := 0; y < 16; y++ {
+			for x := 0; x < 16; x++ {
+				if !image.Pt(x, y).In(rect) && dst.ColorIndexAt(x, y) != 2 {
+					t.Fatalf("%s: pixel (%d, %d) outside of %v was drawn", name, x, y, rect)
+				}
+			}
+		}
+	}
+}
+
+func TestStevensonArceBorders(t *testing.T) {
+	checkBorders(t, "StevensonArce", StevensonArce)
+}
+
+func TestShiauFanBorders(t *testing.T) {
+	checkBorders(t, "ShiauFan", ShiauFan)
+	checkBorders(t, "ShiauFan2", ShiauFan2)
+}
+
+func TestNormalizeMatrix(t *testing.T) {
+	// Floyd-Steinberg diffusing 0.9 of the error
+	scaled := make([][]float32, len(FloydSteinberg))
+	for i, row := range FloydSteinberg {
+		for _, v := range row {
+			scaled[i] = append(scaled[i], v*0.9)
+		}
+	}
+	m := NormalizeMatrix(scaled)
+	var sum float32
+	for i, row := range m {
+		for j, v := range row {
+			sum += v
+			if d := v - FloydSteinberg[i][j]; d > 1e-6 || d < -1e-6 {
+				t.Errorf("weight %d, %d is %v, want %v", i, j, v, FloydSteinberg[i][j])
+			}
+		}
+	}
+	if sum < 1-1e-6 || sum > 1+1e-6 {
+		t.Errorf("weights sum to %v, want 1", sum)
+	}
+	if scaled[0][2] != FloydSteinberg[0][2]*0.9 {
+		t.Error("NormalizeMatrix modified its argument")
+	}
+
+	// the rescaled matrix conserves the error like Floyd-Steinberg
+	src := gradient(64, 16)
+	got, want := image.NewPaletted(src.Rect, blackWhite), image.NewPaletted(src.Rect, blackWhite)
+	New(scaled, WithNormalizedMatrix()).Draw(got, src.Rect, src)
+	NewDither(FloydSteinberg).Draw(want, src.Rect, src)
+	if !bytes.Equal(got.Pix, want.Pix) {
+		t.Error("the normalized matrix differs from Floyd-Steinberg")
+	}
+	if empty := NormalizeMatrix([][]float32{{0, 0}}); empty[0][0] != 0 || empty[0][1] != 0 {
+		t.Errorf("NormalizeMatrix of an empty matrix gives %v", empty)
+	}
+}
+
+func TestReach(t *testing.T) {
+	for name, want := range map[string][3]int{
+		"floyd-steinberg":     {1, 1, 1},
+		"jarvis-judice-ninke": {2, 2, 2},
+		"stucki":              {2, 2, 2},
+		"atkinson":            {2, 1, 2},
+		"burkes":              {1, 2, 2},
+		"sierra":              {2, 2, 2},
+		"two-row-sierra":      {1, 2, 2},
+		"sierra-lite":         {1, 1, 1},
+		"stevenson-arce":      {3, 3, 3},
+		"shiau-fan":           {1, 2, 1},
+		"shiau-fan-2":         {1, 3, 1},
+	} {
+		dit, err := DitherByName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		up, down, left, right := dit.Reach()
+		if up != 0 || [3]int{down, left, right} != want {
+			t.Errorf("%s: Reach() = %d, %d, %d, %d, want 0, %d, %d, %d", name, up, down, left, right, want[0], want[1], want[2])
+		}
+	}
+	// the integer matrix is used when set
+	if _, down, left, right := NewDitherInt(StuckiInt).Reach(); down != 2 || left != 2 || right != 2 {
+		t.Errorf("StuckiInt: Reach() = 0, %d, %d, %d, want 0, 2, 2, 2", down, left, right)
+	}
+}
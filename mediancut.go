@@ -0,0 +1,163 @@
+package dithering
+
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+// histogramEntry is a distinct color of an image and its number of pixels
+type histogramEntry struct {
+	c     color.RGBA
+	count int
+}
+
+// histogram lists the distinct 8-bit colors of an image sorted by value
+func histogram(src image.Image) []histogramEntry {
+	counts := make(map[color.RGBA]int)
+	b := src.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			counts[opaqueRGBA(src.At(x, y))]++
+		}
+	}
+	res := make([]histogramEntry, 0, len(counts))
+	for c, n := range counts {
+		res = append(res, histogramEntry{c, n})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		a, b := res[i].c, res[j].c
+		if a.R != b.R {
+			return a.R < b.R
+		}
+		if a.G != b.G {
+			return a.G < b.G
+		}
+		return a.B < b.B
+	})
+	return res
+}
+
+// cutPoint is a color placed in the space where boxes are split
+type cutPoint struct {
+	key   [3]float64
+	entry histogramEntry
+}
+
+// colorBox is a set of points of the color space
+type colorBox []cutPoint
+
+// longestAxis returns the axis along which the box is the widest and its width
+func (b colorBox) longestAxis() (int, float64) {
+	var axis int
+	var width float64
+	for k := 0; k < 3; k++ {
+		min, max := b[0].key[k], b[0].key[k]
+		for _, p := range b {
+			if p.key[k] < min {
+				min = p.key[k]
+			}
+			if p.key[k] > max {
+				max = p.key[k]
+			}
+		}
+		if max-min > width {
+			axis, width = k, max-min
+		}
+	}
+	return axis, width
+}
+
+// split cuts the box at the weighted median of the given axis
+func (b colorBox) split(axis int) (colorBox, colorBox) {
+	sort.SliceStable(b, func(i, j int) bool { return b[i].key[axis] < b[j].key[axis] })
+	total := 0
+	for _, p := range b {
+		total += p.entry.count
+	}
+	acc := 0
+	for i, p := range b[:len(b)-1] {
+		acc += p.entry.count
+		if 2*acc >= total {
+			return b[:i+1], b[i+1:]
+		}
+	}
+	return b[:len(b)-1], b[len(b)-1:]
+}
+
+// mean returns the average RGB color of the box weighted by pixel counts
+func (b colorBox) mean() color.RGBA {
+	var r, g, bl, total int
+	for _, p := range b {
+		r += int(p.entry.c.R) * p.entry.count
+		g += int(p.entry.c.G) * p.entry.count
+		bl += int(p.entry.c.B) * p.entry.count
+		total += p.entry.count
+	}
+	return color.RGBA{uint8((r + total/2) / total), uint8((g + total/2) / total), uint8((bl + total/2) / total), 255}
+}
+
+// medianCut recursively splits the widest box until n boxes are found
+func medianCut(points []cutPoint, n int) color.Palette {
+	if len(points) == 0 || n < 1 {
+		return color.Palette{}
+	}
+	if len(points) <= n {
+		pal := make(color.Palette, len(points))
+		for i, p := range points {
+			pal[i] = p.entry.c
+		}
+		return pal
+	}
+	boxes := []colorBox{points}
+	for len(boxes) < n {
+		best, bestAxis := -1, 0
+		var bestWidth float64
+		for i, b := range boxes {
+			if len(b) < 2 {
+				continue
+			}
+			if axis, width := b.longestAxis(); best < 0 || width > bestWidth {
+				best, bestAxis, bestWidth = i, axis, width
+			}
+		}
+		if best < 0 {
+			break
+		}
+		b1, b2 := boxes[best].split(bestAxis)
+		boxes[best] = b1
+		boxes = append(boxes, b2)
+	}
+	pal := make(color.Palette, len(boxes))
+	for i, b := range boxes {
+		pal[i] = b.mean()
+	}
+	return pal
+}
+
+// MedianCut generates a palette of at most n colors from the src image
+//
+// The RGB color cube is recursively split along its longest axis.
+// When the image has fewer than n distinct colors, they are returned as is.
+func MedianCut(src image.Image, n int) color.Palette {
+	hist := histogram(src)
+	points := make([]cutPoint, len(hist))
+	for i, e := range hist {
+		points[i] = cutPoint{[3]float64{float64(e.c.R), float64(e.c.G), float64(e.c.B)}, e}
+	}
+	return medianCut(points, n)
+}
+
+// MedianCutLab generates a palette of at most n colors from the src image
+//
+// It works like MedianCut but boxes are split in CIELAB space, along the
+// axis with the largest perceptual spread.
+func MedianCutLab(src image.Image, n int) color.Palette {
+	hist := histogram(src)
+	points := make([]cutPoint, len(hist))
+	for i, e := range hist {
+		l, a, b := toLab(e.c)
+		points[i] = cutPoint{[3]float64{l, a, b}, e}
+	}
+	return medianCut(points, n)
+}
@@ -0,0 +1,63 @@
+package dithering
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+// tinted returns a size x size image whose channels stay within
+// [50, 150], [100, 200] and [20, 60]
+func tinted(size int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.SetRGBA(x, y, color.RGBA{uint8(50 + x*100/size), uint8(100 + y*100/size), uint8(20 + (x+y)*20/size), 255})
+		}
+	}
+	return img
+}
+
+// inGamut reports whether every color of pal is within the channel ranges of tinted
+func inGamut(pal color.Palette) bool {
+	for _, c := range pal {
+		r, g, b := rgb8(c)
+		if r < 50 || r > 150 || g < 100 || g > 200 || b < 20 || b > 60 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMedianCut(t *testing.T) {
+	src := tinted(64)
+	pal := MedianCut(src, 16)
+	if len(pal) != 16 {
+		t.Errorf("%d colors, want 16", len(pal))
+	}
+	if !inGamut(pal) {
+		t.Errorf("palette %v out of the source gamut", pal)
+	}
+
+	// fewer distinct colors than requested
+	few := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for i := range few.Pix {
+		few.Pix[i] = 255
+	}
+	few.SetRGBA(0, 0, color.RGBA{255, 0, 0, 255})
+	if pal := MedianCut(few, 16); len(pal) != 2 {
+		t.Errorf("%d colors for 2 distinct ones", len(pal))
+	}
+}
+
+func TestMedianCutLab(t *testing.T) {
+	src := colorful(64)
+	lab, rgb := MedianCutLab(src, 16), MedianCut(src, 16)
+	if len(lab) != 16 {
+		t.Errorf("%d colors, want 16", len(lab))
+	}
+	if reflect.DeepEqual(lab, rgb) {
+		t.Error("the CIELAB and RGB palettes are the same")
+	}
+}
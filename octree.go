@@ -0,0 +1,128 @@
+package dithering
+
+import (
+	"image"
+	"image/color"
+)
+
+// octreeDepth is the number of levels of the octree, one per bit of a channel
+const octreeDepth = 8
+
+// octreeNode is a node of the color octree, leaves accumulate their pixels
+type octreeNode struct {
+	leaf     bool
+	count    int
+	r, g, b  int
+	children [8]*octreeNode
+}
+
+// octree quantizes colors by merging the deepest nodes first
+type octree struct {
+	root       *octreeNode
+	leaves     int
+	reducibles [octreeDepth][]*octreeNode
+}
+
+// childIndex returns the child of a node at the given level containing the color
+func childIndex(c color.RGBA, level int) int {
+	shift := uint(7 - level)
+	return int((c.R>>shift)&1)<<2 | int((c.G>>shift)&1)<<1 | int((c.B>>shift)&1)
+}
+
+// insert adds a color to the octree
+func (t *octree) insert(c color.RGBA) {
+	node := t.root
+	for level := 0; !node.leaf; level++ {
+		i := childIndex(c, level)
+		if node.children[i] == nil {
+			child := &octreeNode{leaf: level+1 == octreeDepth}
+			if child.leaf {
+				t.leaves++
+			} else {
+				t.reducibles[level+1] = append(t.reducibles[level+1], child)
+			}
+			node.children[i] = child
+		}
+		node = node.children[i]
+	}
+	node.count++
+	node.r += int(c.R)
+	node.g += int(c.G)
+	node.b += int(c.B)
+}
+
+// reduce merges the children of the most recent node of the deepest level
+func (t *octree) reduce() {
+	level := octreeDepth - 1
+	for level > 0 && len(t.reducibles[level]) == 0 {
+		level--
+	}
+	nodes := t.reducibles[level]
+	if len(nodes) == 0 {
+		return
+	}
+	node := nodes[len(nodes)-1]
+	t.reducibles[level] = nodes[:len(nodes)-1]
+
+	merged := 0
+	for i, child := range node.children {
+		if child == nil {
+			continue
+		}
+		node.count += child.count
+		node.r += child.r
+		node.g += child.g
+		node.b += child.b
+		node.children[i] = nil
+		merged++
+	}
+	node.leaf = true
+	t.leaves -= merged - 1
+}
+
+// palette returns the mean color of every leaf
+func (t *octree) palette() color.Palette {
+	pal := make(color.Palette, 0, t.leaves)
+	var walk func(n *octreeNode)
+	walk = func(n *octreeNode) {
+		if n.leaf {
+			if n.count > 0 {
+				pal = append(pal, color.RGBA{
+					uint8((n.r + n.count/2) / n.count),
+					uint8((n.g + n.count/2) / n.count),
+					uint8((n.b + n.count/2) / n.count),
+					255})
+			}
+			return
+		}
+		for _, child := range n.children {
+			if child != nil {
+				walk(child)
+			}
+		}
+	}
+	walk(t.root)
+	return pal
+}
+
+// OctreeQuantize generates a palette of at most maxColors colors from the src image
+//
+// Colors are inserted in an octree whose deepest nodes are merged as soon as
+// there are too many leaves, so memory is bounded regardless of the image size.
+func OctreeQuantize(src image.Image, maxColors int) color.Palette {
+	if maxColors < 1 {
+		return color.Palette{}
+	}
+	t := &octree{root: &octreeNode{}}
+	t.reducibles[0] = []*octreeNode{t.root}
+	b := src.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			t.insert(opaqueRGBA(src.At(x, y)))
+			for t.leaves > maxColors {
+				t.reduce()
+			}
+		}
+	}
+	return t.palette()
+}
@@ -0,0 +1,42 @@
+package dithering
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// fourColors returns an image made of four flat quadrants
+func fourColors() *image.RGBA {
+	colors := []color.RGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}, {255, 255, 0, 255}}
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			img.SetRGBA(x, y, colors[2*(y/8)+x/8])
+		}
+	}
+	return img
+}
+
+func TestOctreeQuantize(t *testing.T) {
+	src := colorful(64)
+	for _, n := range []int{1, 2, 7, 16, 64} {
+		if pal := OctreeQuantize(src, n); len(pal) > n || len(pal) == 0 {
+			t.Errorf("%d colors for at most %d", len(pal), n)
+		}
+	}
+	pal := OctreeQuantize(fourColors(), 16)
+	if len(pal) != 4 {
+		t.Fatalf("%d colors for a 4 colors image", len(pal))
+	}
+	src4 := fourColors()
+	seen := make(map[color.RGBA]bool)
+	for _, c := range pal {
+		seen[color.RGBAModel.Convert(c).(color.RGBA)] = true
+	}
+	for _, pt := range []image.Point{{0, 0}, {8, 0}, {0, 8}, {8, 8}} {
+		if c := src4.RGBAAt(pt.X, pt.Y); !seen[c] {
+			t.Errorf("%v missing from %v", c, pal)
+		}
+	}
+}
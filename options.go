@@ -0,0 +1,48 @@
+package dithering
+
+// Option configures a Dither prepared by New
+type Option func(*Dither)
+
+// New prepares a dithering algorithm like NewDither, then applies the options
+func New(matrix [][]float32, opts ...Option) Dither {
+	dit := NewDither(matrix)
+	for _, opt := range opts {
+		opt(&dit)
+	}
+	return dit
+}
+
+// WithSerpentine alternates the scan direction on every row
+func WithSerpentine() Option {
+	return func(dit *Dither) {
+		dit.Serpentine = true
+	}
+}
+
+// WithDamping sets the damping applied to the carried error
+func WithDamping(f float32) Option {
+	return func(dit *Dither) {
+		dit.ErrorDamping = f
+	}
+}
+
+// WithDistance sets the function comparing colors when matching the palette
+func WithDistance(fn DistanceFunc) Option {
+	return func(dit *Dither) {
+		dit.Distance = fn
+	}
+}
+
+// WithGamma sets the gamma correction applied to the source
+func WithGamma(g float32) Option {
+	return func(dit *Dither) {
+		dit.Gamma = g
+	}
+}
+
+// WithNormalizedMatrix rescales the matrix so that its weights sum to 1
+func WithNormalizedMatrix() Option {
+	return func(dit *Dither) {
+		dit.Matrix = NormalizeMatrix(dit.Matrix)
+	}
+}
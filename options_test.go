@@ -0,0 +1,44 @@
+package dithering
+
+import (
+	"bytes"
+	"image"
+	"math"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	dit := New(Atkinson, WithSerpentine(), WithDamping(0.5), WithDistance(RedmeanDistance), WithGamma(2.2))
+	if !dit.Serpentine || dit.ErrorDamping != 0.5 || dit.Distance == nil || dit.Gamma != 2.2 {
+		t.Fatalf("options not applied: Serpentine %v, ErrorDamping %v, Distance %v, Gamma %v", dit.Serpentine, dit.ErrorDamping, dit.Distance != nil, dit.Gamma)
+	}
+
+	// the options give the same output as the fields
+	want := NewDither(Atkinson)
+	want.Serpentine = true
+	want.ErrorDamping = 0.5
+	want.Distance = RedmeanDistance
+	want.Gamma = 2.2
+	src := colorful(32)
+	got, exp := image.NewPaletted(src.Rect, C64Palette), image.NewPaletted(src.Rect, C64Palette)
+	dit.Draw(got, src.Rect, src)
+	want.Draw(exp, src.Rect, src)
+	if !bytes.Equal(got.Pix, exp.Pix) {
+		t.Error("New differs from NewDither with the same fields")
+	}
+
+	// without options New is NewDither
+	if d := New(FloydSteinberg); d.ErrorDamping != 0.75 || d.DiffusionStrength != 1 || d.Saturation != 0 || d.Serpentine {
+		t.Error("New without options differs from NewDither")
+	}
+	d := New([][]float32{{0, 0, 0.45}, {0.15, 0.2, 0.1}}, WithNormalizedMatrix())
+	var sum float32
+	for _, row := range d.Matrix {
+		for _, v := range row {
+			sum += v
+		}
+	}
+	if math.Abs(float64(sum)-1) > 1e-6 {
+		t.Errorf("WithNormalizedMatrix gives weights summing to %v", sum)
+	}
+}
@@ -0,0 +1,201 @@
+package dithering
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"sync"
+)
+
+var (
+	// Bayer2 is the 2x2 Bayer threshold map
+	Bayer2 = [][]float32{{0.0 / 4.0, 2.0 / 4.0}, {3.0 / 4.0, 1.0 / 4.0}}
+	// Bayer4 is the 4x4 Bayer threshold map
+	Bayer4 = [][]float32{
+		{0.0 / 16.0, 8.0 / 16.0, 2.0 / 16.0, 10.0 / 16.0},
+		{12.0 / 16.0, 4.0 / 16.0, 14.0 / 16.0, 6.0 / 16.0},
+		{3.0 / 16.0, 11.0 / 16.0, 1.0 / 16.0, 9.0 / 16.0},
+		{15.0 / 16.0, 7.0 / 16.0, 13.0 / 16.0, 5.0 / 16.0}}
+	// Bayer8 is the 8x8 Bayer threshold map
+	Bayer8 = [][]float32{
+		{0.0 / 64.0, 32.0 / 64.0, 8.0 / 64.0, 40.0 / 64.0, 2.0 / 64.0, 34.0 / 64.0, 10.0 / 64.0, 42.0 / 64.0},
+		{48.0 / 64.0, 16.0 / 64.0, 56.0 / 64.0, 24.0 / 64.0, 50.0 / 64.0, 18.0 / 64.0, 58.0 / 64.0, 26.0 / 64.0},
+		{12.0 / 64.0, 44.0 / 64.0, 4.0 / 64.0, 36.0 / 64.0, 14.0 / 64.0, 46.0 / 64.0, 6.0 / 64.0, 38.0 / 64.0},
+		{60.0 / 64.0, 28.0 / 64.0, 52.0 / 64.0, 20.0 / 64.0, 62.0 / 64.0, 30.0 / 64.0, 54.0 / 64.0, 22.0 / 64.0},
+		{3.0 / 64.0, 35.0 / 64.0, 11.0 / 64.0, 43.0 / 64.0, 1.0 / 64.0, 33.0 / 64.0, 9.0 / 64.0, 41.0 / 64.0},
+		{51.0 / 64.0, 19.0 / 64.0, 59.0 / 64.0, 27.0 / 64.0, 49.0 / 64.0, 17.0 / 64.0, 57.0 / 64.0, 25.0 / 64.0},
+		{15.0 / 64.0, 47.0 / 64.0, 7.0 / 64.0, 39.0 / 64.0, 13.0 / 64.0, 45.0 / 64.0, 5.0 / 64.0, 37.0 / 64.0},
+		{63.0 / 64.0, 31.0 / 64.0, 55.0 / 64.0, 23.0 / 64.0, 61.0 / 64.0, 29.0 / 64.0, 53.0 / 64.0, 21.0 / 64.0}}
+)
+
+// OrderedDither represent ordered dithering algorithm implementation
+//
+// Every pixel is dithered independently from its neighbors
+type OrderedDither struct {
+	// Threshold is the threshold map, its values are in [0, 1)
+	Threshold [][]float32
+	// Parallelism is the number of goroutines drawing bands of rows, 0 and 1
+	// draw serially. dst must support concurrent Set on distinct rows.
+	Parallelism int
+}
+
+// NewOrderedDither prepares an ordered dithering algorithm
+func NewOrderedDither(threshold [][]float32) OrderedDither {
+	return OrderedDither{Threshold: threshold}
+}
+
+// workers returns the number of goroutines to use for the given number of rows
+func workers(parallelism int, rows int) int {
+	if parallelism > rows {
+		parallelism = rows
+	}
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	return parallelism
+}
+
+// parallelRows calls drawRow for every row of rect, splitting them in
+// contiguous bands drawn by n goroutines
+func parallelRows(rect image.Rectangle, n int, drawRow func(y int)) {
+	if n == 1 {
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			drawRow(y)
+		}
+		return
+	}
+	var wg sync.WaitGroup
+	band := (rect.Dy() + n - 1) / n
+	for start := rect.Min.Y; start < rect.Max.Y; start += band {
+		end := start + band
+		if end > rect.Max.Y {
+			end = rect.Max.Y
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for y := start; y < end; y++ {
+				drawRow(y)
+			}
+		}(start, end)
+	}
+	wg.Wait()
+}
+
+// paletteSpacing estimates the distance between neighbor colors of a palette
+//
+// It is the mean distance between each color and its closest neighbor,
+// using the largest channel difference
+func paletteSpacing(pal color.Palette) float32 {
+	if len(pal) < 2 {
+		return 0
+	}
+	var total float32
+	for i, c1 := range pal {
+		r1, g1, b1, _ := c1.RGBA()
+		var minDiff uint16 = 1<<16 - 1
+		for j, c2 := range pal {
+			if i == j {
+				continue
+			}
+			r2, g2, b2, _ := c2.RGBA()
+			diff := abs(int16(r1>>8) - int16(r2>>8))
+			if d := abs(int16(g1>>8) - int16(g2>>8)); d > diff {
+				diff = d
+			}
+			if d := abs(int16(b1>>8) - int16(b2>>8)); d > diff {
+				diff = d
+			}
+			if diff < minDiff {
+				minDiff = diff
+			}
+		}
+		total += float32(minDiff)
+	}
+	return total / float32(len(pal))
+}
+
+// Draw applies an ordered dithering algorithm to the src image
+//
+// Errors are ignored, use DrawE to retrieve them
+func (dit OrderedDither) Draw(dst draw.Image, rect image.Rectangle, src image.Image) {
+	_ = dit.DrawE(dst, rect, src)
+}
+
+// DrawE applies an ordered dithering algorithm to the src image
+//
+// It returns an error if the destination is not paletted or if its palette is empty
+func (dit OrderedDither) DrawE(dst draw.Image, rect image.Rectangle, src image.Image) error {
+	return drawE(dst, rect, src, dit.DrawWithPalette)
+}
+
+// DrawWithPalette applies an ordered dithering algorithm to the src image
+// using the given palette, like Dither.DrawWithPalette
+//
+// It returns an error if the palette is empty.
+// rect is restricted to the bounds of dst and src, nothing is read or written outside of them
+func (dit OrderedDither) DrawWithPalette(dst draw.Image, rect image.Rectangle, src image.Image, pal color.Palette) error {
+	if len(pal) == 0 {
+		return ErrEmptyPalette
+	}
+	rect = rect.Intersect(dst.Bounds()).Intersect(src.Bounds())
+	spacing := paletteSpacing(pal)
+	m := newMatcher(pal, nil)
+	out := newPaletteWriter(dst, pal)
+	parallelRows(rect, workers(dit.Parallelism, rect.Dy()), func(y int) {
+		dit.drawRow(out, rect, src, m, spacing, y)
+	})
+	return nil
+}
+
+// drawRow dithers a single row, rows are independent from each other
+func (dit OrderedDither) drawRow(out paletteWriter, rect image.Rectangle, src image.Image, m matcher, spacing float32, y int) {
+	if len(dit.Threshold) == 0 {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			i, _, _ := findColor(PixelError{}, src.At(x, y), m, 1)
+			out.set(x, y, i)
+		}
+		return
+	}
+	row := dit.Threshold[(y-rect.Min.Y)%len(dit.Threshold)]
+	for x := rect.Min.X; x < rect.Max.X; x++ {
+		offset := (row[(x-rect.Min.X)%len(row)] - 0.5) * spacing
+		i, _, _ := findColor(PixelError{offset, offset, offset, 0}, src.At(x, y), m, 1)
+		out.set(x, y, i)
+	}
+}
+
+// BayerMatrix generates the n x n Bayer threshold map
+//
+// n must be a power of two, BayerMatrix panics otherwise
+func BayerMatrix(n int) [][]float32 {
+	if n < 1 || n&(n-1) != 0 {
+		panic("dithering: Bayer matrix size must be a power of two")
+	}
+	// building the integer map recursively from the 1x1 map
+	m := [][]int{{0}}
+	for size := 1; size < n; size *= 2 {
+		next := make([][]int, 2*size)
+		for y := range next {
+			next[y] = make([]int, 2*size)
+		}
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				v := 4 * m[y][x]
+				next[y][x] = v
+				next[y][x+size] = v + 2
+				next[y+size][x] = v + 3
+				next[y+size][x+size] = v + 1
+			}
+		}
+		m = next
+	}
+	res := make([][]float32, n)
+	for y := range res {
+		res[y] = make([]float32, n)
+		for x := range res[y] {
+			res[y][x] = float32(m[y][x]) / float32(n*n)
+		}
+	}
+	return res
+}
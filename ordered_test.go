@@ -0,0 +1,122 @@
+package dithering
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"runtime"
+	"testing"
+)
+
+func TestOrderedDither(t *testing.T) {
+	src := image.NewUniform(color.Gray{128})
+	r := image.Rect(0, 0, 16, 16)
+	for _, threshold := range [][][]float32{Bayer2, Bayer4, Bayer8} {
+		n := len(threshold)
+		dst := image.NewPaletted(r, blackWhite)
+		NewOrderedDither(threshold).Draw(dst, r, src)
+		// every pixel only depends on its position in the threshold map
+		for y := 0; y < 16; y++ {
+			for x := 0; x < 16; x++ {
+				if dst.ColorIndexAt(x, y) != dst.ColorIndexAt(x%n, y%n) {
+					t.Fatalf("%dx%[1]d: pixel (%d, %d) differs from the tile", n, x, y)
+				}
+			}
+		}
+		// half of the tile is white
+		if white := bytes.Count(dst.Pix, []byte{1}); white != 16*16/2 {
+			t.Errorf("%dx%[1]d: %d white pixels, want %d", n, white, 16*16/2)
+		}
+	}
+}
+
+func TestBayerMatrix(t *testing.T) {
+	// the canonical 4x4 Bayer map
+	want := [][]int{{0, 8, 2, 10}, {12, 4, 14, 6}, {3, 11, 1, 9}, {15, 7, 13, 5}}
+	m := BayerMatrix(4)
+	for y, row := range want {
+		for x, v := range row {
+			if m[y][x] != float32(v)/16 {
+				t.Fatalf("BayerMatrix(4)[%d][%d] = %v, want %v/16", y, x, m[y][x], v)
+			}
+		}
+	}
+	for _, n := range []int{2, 8} {
+		for y, row := range BayerMatrix(n) {
+			for x, v := range row {
+				if want := [][][]float32{2: Bayer2, 8: Bayer8}[n][y][x]; v != want {
+					t.Fatalf("BayerMatrix(%d)[%d][%d] = %v, want %v", n, y, x, v, want)
+				}
+			}
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("BayerMatrix(6) does not panic")
+		}
+	}()
+	BayerMatrix(6)
+}
+
+func TestOrderedParallelism(t *testing.T) {
+	src := colorful(128)
+	draw := func(parallelism int) []uint8 {
+		dst := image.NewPaletted(src.Rect, C64Palette)
+		dit := NewOrderedDither(Bayer8)
+		dit.Parallelism = parallelism
+		dit.Draw(dst, src.Rect, src)
+		return dst.Pix
+	}
+	serial := draw(1)
+	for _, n := range []int{0, 3, 8} {
+		if !bytes.Equal(draw(n), serial) {
+			t.Errorf("Parallelism %d differs from the serial output", n)
+		}
+	}
+}
+
+func BenchmarkOrderedParallelism(b *testing.B) {
+	src := colorful(4096)
+	for _, n := range []int{1, runtime.GOMAXPROCS(0)} {
+		b.Run(fmt.Sprintf("parallelism=%d", n), func(b *testing.B) {
+			dst := image.NewPaletted(src.Rect, C64Palette)
+			dit := NewOrderedDither(Bayer8)
+			dit.Parallelism = n
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				dit.Draw(dst, src.Rect, src)
+			}
+		})
+	}
+}
+
+func TestOrderedRect(t *testing.T) {
+	src := gradient(16, 16)
+	dst := image.NewPaletted(image.Rect(0, 0, 8, 8), blackWhite)
+	want := image.NewPaletted(dst.Rect, blackWhite)
+	dit := NewOrderedDither(Bayer4)
+	dit.Draw(want, want.Rect, src)
+	// rect is clipped to the destination, the smaller image
+	if err := dit.DrawE(dst, image.Rect(0, 0, 32, 32), src); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dst.Pix, want.Pix) {
+		t.Error("a rect larger than the destination changes the output")
+	}
+	// and to the source, the pixels outside of it are left white
+	dst = image.NewPaletted(image.Rect(0, 0, 32, 32), blackWhite)
+	for i := range dst.Pix {
+		dst.Pix[i] = 1
+	}
+	dit.Draw(dst, dst.Rect, src)
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			if !(image.Point{x, y}.In(src.Rect)) && dst.ColorIndexAt(x, y) != 1 {
+				t.Fatalf("pixel (%d, %d) outside of the source is drawn", x, y)
+			}
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package dithering
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// ostromoukhovCoefficients holds the right, down-left and down weights and
+// their sum for the intensities 0 to 127, higher intensities are symmetric
+var ostromoukhovCoefficients = [128][4]int{
+	{13, 0, 5, 18}, {13, 0, 5, 18}, {21, 0, 10, 31}, {7, 0, 4, 11},
+	{8, 0, 5, 13}, {47, 3, 28, 78}, {23, 3, 13, 39}, {15, 3, 8, 26},
+	{22, 6, 11, 39}, {43, 15, 20, 78}, {7, 3, 3, 13}, {501, 224, 211, 936},
+	{249, 116, 103, 468}, {165, 80, 67, 312}, {123, 62, 49, 234}, {489, 256, 191, 936},
+	{81, 44, 31, 156}, {483, 272, 181, 936}, {60, 35, 22, 117}, {53, 32, 19, 104},
+	{237, 148, 83, 468}, {471, 304, 161, 936}, {3, 2, 1, 6}, {481, 314, 185, 980},
+	{354, 226, 155, 735}, {1389, 866, 685, 2940}, {227, 138, 125, 490}, {267, 158, 163, 588},
+	{327, 188, 220, 735}, {61, 34, 45, 140}, {627, 338, 505, 1470}, {1227, 638, 1075, 2940},
+	{20, 10, 19, 49}, {1937, 1000, 1767, 4704}, {977, 520, 855, 2352}, {657, 360, 551, 1568},
+	{71, 40, 57, 168}, {2005, 1160, 1539, 4704}, {337, 200, 247, 784}, {2039, 1240, 1425, 4704},
+	{257, 160, 171, 588}, {691, 440, 437, 1568}, {1045, 680, 627, 2352}, {301, 200, 171, 672},
+	{177, 120, 95, 392}, {2141, 1480, 1083, 4704}, {1079, 760, 513, 2352}, {725, 520, 323, 1568},
+	{137, 100, 57, 294}, {2209, 1640, 855, 4704}, {53, 40, 19, 112}, {2243, 1720, 741, 4704},
+	{565, 440, 171, 1176}, {759, 600, 209, 1568}, {1147, 920, 285, 2352}, {2311, 1880, 513, 4704},
+	{97, 80, 19, 196}, {335, 280, 57, 672}, {1181, 1000, 171, 2352}, {793, 680, 95, 1568},
+	{599, 520, 57, 1176}, {2413, 2120, 171, 4704}, {405, 360, 19, 784}, {2447, 2200, 57, 4704},
+	{11, 10, 0, 21}, {158, 151, 3, 312}, {178, 179, 7, 364}, {1030, 1091, 63, 2184},
+	{248, 277, 21, 546}, {318, 375, 35, 728}, {458, 571, 63, 1092}, {878, 1159, 147, 2184},
+	{5, 7, 1, 13}, {172, 181, 37, 390}, {97, 76, 22, 195}, {72, 41, 17, 130},
+	{119, 47, 29, 195}, {4, 1, 1, 6}, {4, 1, 1, 6}, {4, 1, 1, 6},
+	{4, 1, 1, 6}, {4, 1, 1, 6}, {4, 1, 1, 6}, {4, 1, 1, 6},
+	{4, 1, 1, 6}, {4, 1, 1, 6}, {65, 18, 17, 100}, {95, 29, 26, 150},
+	{185, 62, 53, 300}, {30, 11, 9, 50}, {35, 14, 11, 60}, {85, 37, 28, 150},
+	{55, 26, 19, 100}, {80, 41, 29, 150}, {155, 86, 59, 300}, {5, 3, 2, 10},
+	{5, 3, 2, 10}, {5, 3, 2, 10}, {5, 3, 2, 10}, {5, 3, 2, 10},
+	{5, 3, 2, 10}, {5, 3, 2, 10}, {5, 3, 2, 10}, {5, 3, 2, 10},
+	{5, 3, 2, 10}, {5, 3, 2, 10}, {5, 3, 2, 10}, {5, 3, 2, 10},
+	{305, 176, 119, 600}, {155, 86, 59, 300}, {105, 56, 39, 200}, {80, 41, 29, 150},
+	{65, 32, 23, 120}, {55, 26, 19, 100}, {335, 152, 113, 600}, {85, 37, 28, 150},
+	{115, 48, 37, 200}, {35, 14, 11, 60}, {355, 136, 109, 600}, {30, 11, 9, 50},
+	{365, 128, 107, 600}, {185, 62, 53, 300}, {25, 8, 7, 40}, {95, 29, 26, 150},
+	{385, 112, 103, 600}, {65, 18, 17, 100}, {395, 104, 101, 600}, {4, 1, 1, 6},
+}
+
+// OstromoukhovDither represent Ostromoukhov variable-coefficient error diffusion
+//
+// The image is converted to grayscale and the diffusion weights depend on the
+// intensity of each pixel. Rows are scanned in serpentine order.
+type OstromoukhovDither struct{}
+
+// NewOstromoukhovDither prepares an Ostromoukhov dithering algorithm
+func NewOstromoukhovDither() OstromoukhovDither {
+	return OstromoukhovDither{}
+}
+
+// Draw applies an Ostromoukhov dithering algorithm to the src image
+//
+// Errors are ignored, use DrawE to retrieve them
+func (dit OstromoukhovDither) Draw(dst draw.Image, rect image.Rectangle, src image.Image) {
+	_ = dit.DrawE(dst, rect, src)
+}
+
+// DrawE applies an Ostromoukhov dithering algorithm to the src image
+//
+// It returns an error if the destination is not paletted or if its palette is empty
+func (dit OstromoukhovDither) DrawE(dst draw.Image, rect image.Rectangle, src image.Image) error {
+	return drawE(dst, rect, src, dit.DrawWithPalette)
+}
+
+// DrawWithPalette applies an Ostromoukhov dithering algorithm to the src image
+// using the given palette, like Dither.DrawWithPalette
+//
+// It returns an error if the palette is empty
+func (dit OstromoukhovDither) DrawWithPalette(dst draw.Image, rect image.Rectangle, src image.Image, pal color.Palette) error {
+	if len(pal) == 0 {
+		return ErrEmptyPalette
+	}
+	m := newMatcher(grayPalette(pal), nil)
+	out := newPaletteWriter(dst, pal)
+	w := rect.Dx()
+	// errors of the current and the next rows
+	cur, next := make([]float32, w), make([]float32, w)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		dir := 1
+		if (y-rect.Min.Y)%2 == 1 {
+			dir = -1
+		}
+		for k := 0; k < w; k++ {
+			i := k
+			if dir < 0 {
+				i = w - 1 - k
+			}
+			x := rect.Min.X + i
+
+			level := color.GrayModel.Convert(src.At(x, y)).(color.Gray).Y
+			v := int16(clampFloat(float32(level)+cur[i], 0, 255))
+			index, _ := m.closest(v, v, v)
+			out.set(x, y, index)
+			e := float32(v - m.rgb[index][0])
+
+			if level > 127 {
+				level = 255 - level
+			}
+			coefs := ostromoukhovCoefficients[level]
+			sum := float32(coefs[3])
+			if j := i + dir; j >= 0 && j < w {
+				cur[j] += e * float32(coefs[0]) / sum
+			}
+			if j := i - dir; j >= 0 && j < w {
+				next[j] += e * float32(coefs[1]) / sum
+			}
+			next[i] += e * float32(coefs[2]) / sum
+		}
+		cur, next = next, cur
+		for i := range next {
+			next[i] = 0
+		}
+	}
+	return nil
+}
+
+// grayPalette converts every color of a palette to its gray level
+func grayPalette(pal color.Palette) color.Palette {
+	res := make(color.Palette, len(pal))
+	for i, c := range pal {
+		res[i] = color.GrayModel.Convert(c)
+	}
+	return res
+}
@@ -0,0 +1,29 @@
+package dithering
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestOstromoukhovRamp(t *testing.T) {
+	src := gradient(256, 64)
+	dst := image.NewPaletted(src.Rect, blackWhite)
+	NewOstromoukhovDither().Draw(dst, src.Rect, src)
+	// the density of white of every band of 16 columns follows the ramp
+	const band = 16
+	for x0 := 0; x0 < 256; x0 += band {
+		white, level := 0, 0
+		for y := 0; y < 64; y++ {
+			for x := x0; x < x0+band; x++ {
+				white += int(dst.Pix[dst.PixOffset(x, y)])
+				level += int(src.Pix[src.PixOffset(x, y)])
+			}
+		}
+		density := float64(white) / (band * 64)
+		want := float64(level) / (band * 64 * 255)
+		if math.Abs(density-want) > 0.03 {
+			t.Errorf("columns %d to %d: density %.3f, want %.3f", x0, x0+band, density, want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package dithering
+
+import (
+	"image"
+	"image/color"
+)
+
+// maxSampledColors is the maximum number of colors PaletteFromImage samples
+const maxSampledColors = 256
+
+// WebSafePalette is the 216 colors web-safe palette
+var WebSafePalette = webSafePalette()
+
+// webSafePalette builds the palette whose channels are multiples of 51
+func webSafePalette() color.Palette {
+	pal := make(color.Palette, 0, 216)
+	for r := 0; r < 6; r++ {
+		for g := 0; g < 6; g++ {
+			for b := 0; b < 6; b++ {
+				pal = append(pal, color.RGBA{uint8(r * 51), uint8(g * 51), uint8(b * 51), 255})
+			}
+		}
+	}
+	return pal
+}
+
+// GrayPalette returns levels evenly spaced grays from black to white
+//
+// GrayPalette(2) is the black and white palette
+func GrayPalette(levels int) color.Palette {
+	if levels < 1 {
+		return color.Palette{}
+	}
+	if levels == 1 {
+		return color.Palette{color.Gray{0}}
+	}
+	pal := make(color.Palette, levels)
+	for i := range pal {
+		pal[i] = color.Gray{uint8((i*255 + (levels-1)/2) / (levels - 1))}
+	}
+	return pal
+}
+
+// PaletteFromImage returns the palette of an image
+//
+// The palette of an *image.Paletted is returned as is. For other images, the
+// first distinct colors are sampled in scan order, up to 256 colors.
+func PaletteFromImage(img image.Image) color.Palette {
+	if p, ok := img.(*image.Paletted); ok {
+		return p.Palette
+	}
+	seen := make(map[color.RGBA]bool)
+	pal := color.Palette{}
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			if seen[c] {
+				continue
+			}
+			seen[c] = true
+			pal = append(pal, c)
+			if len(pal) == maxSampledColors {
+				return pal
+			}
+		}
+	}
+	return pal
+}
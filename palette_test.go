@@ -0,0 +1,52 @@
+package dithering
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestWebSafePalette(t *testing.T) {
+	if len(WebSafePalette) != 216 {
+		t.Fatalf("%d colors, want 216", len(WebSafePalette))
+	}
+	src := colorful(64)
+	dst := image.NewPaletted(src.Rect, WebSafePalette)
+	NewDither(FloydSteinberg).Draw(dst, src.Rect, src)
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			r, g, b := rgb8(dst.At(x, y))
+			if r%51 != 0 || g%51 != 0 || b%51 != 0 {
+				t.Fatalf("pixel (%d, %d) is not web-safe: %d, %d, %d", x, y, r, g, b)
+			}
+		}
+	}
+}
+
+func TestGrayPalette(t *testing.T) {
+	if pal := GrayPalette(2); pal[0] != (color.Gray{0}) || pal[1] != (color.Gray{255}) {
+		t.Errorf("GrayPalette(2) = %v, want black and white", pal)
+	}
+	src := gradient(64, 8)
+	dst := image.NewPaletted(src.Rect, GrayPalette(4))
+	NewDither(FloydSteinberg).Draw(dst, src.Rect, src)
+	seen := make(map[uint8]bool)
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 64; x++ {
+			seen[color.GrayModel.Convert(dst.At(x, y)).(color.Gray).Y] = true
+		}
+	}
+	if len(seen) != 4 || !seen[0] || !seen[85] || !seen[170] || !seen[255] {
+		t.Errorf("grays %v, want 0, 85, 170 and 255", seen)
+	}
+}
+
+func TestPaletteFromImage(t *testing.T) {
+	p := image.NewPaletted(image.Rect(0, 0, 2, 2), WebSafePalette)
+	if pal := PaletteFromImage(p); len(pal) != len(WebSafePalette) || &pal[0] != &WebSafePalette[0] {
+		t.Error("the palette of a paletted image is not returned as is")
+	}
+	if pal := PaletteFromImage(fourColors()); len(pal) != 4 {
+		t.Errorf("%d colors sampled from a 4 colors image", len(pal))
+	}
+}
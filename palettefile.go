@@ -0,0 +1,228 @@
+package dithering
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrPaletteFormat is returned when a palette file is malformed
+var ErrPaletteFormat = errors.New("dithering: malformed palette file")
+
+// parseChannel parses a decimal 8-bit channel value
+func parseChannel(s string) (uint8, error) {
+	v, err := strconv.ParseUint(s, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid channel value %q", s)
+	}
+	return uint8(v), nil
+}
+
+// parseRGB parses the first three decimal fields of a line as a color
+func parseRGB(fields []string) (color.RGBA, error) {
+	if len(fields) < 3 {
+		return color.RGBA{}, fmt.Errorf("expected 3 channels, got %d", len(fields))
+	}
+	var ch [3]uint8
+	for i := range ch {
+		v, err := parseChannel(fields[i])
+		if err != nil {
+			return color.RGBA{}, err
+		}
+		ch[i] = v
+	}
+	return color.RGBA{ch[0], ch[1], ch[2], 255}, nil
+}
+
+// LoadGPL reads a GIMP palette
+//
+// Comments as well as the Name and Columns lines are skipped,
+// color names are ignored.
+func LoadGPL(r io.Reader) (color.Palette, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "GIMP Palette" {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: line 1: missing GIMP Palette header", ErrPaletteFormat)
+	}
+	pal := color.Palette{}
+	for line := 2; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") ||
+			strings.HasPrefix(text, "Name:") || strings.HasPrefix(text, "Columns:") {
+			continue
+		}
+		c, err := parseRGB(strings.Fields(text))
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrPaletteFormat, line, err)
+		}
+		pal = append(pal, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return pal, nil
+}
+
+// actColors is the number of colors stored in an Adobe Color Table
+const actColors = 256
+
+// LoadACT reads an Adobe Color Table
+//
+// The table holds 256 RGB triples optionally followed by the number of
+// colors and the index of the transparent color. When present, the palette
+// is truncated to that number of colors and the transparent color is fully
+// transparent.
+func LoadACT(r io.Reader) (color.Palette, error) {
+	buf := make([]byte, 3*actColors)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPaletteFormat, err)
+	}
+	pal := make(color.Palette, actColors)
+	for i := range pal {
+		pal[i] = color.RGBA{buf[3*i], buf[3*i+1], buf[3*i+2], 255}
+	}
+
+	footer := make([]byte, 4)
+	n, err := io.ReadFull(r, footer)
+	if n == 0 && err == io.EOF {
+		return pal, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: truncated footer: %v", ErrPaletteFormat, err)
+	}
+	count := int(binary.BigEndian.Uint16(footer[0:2]))
+	transparent := int(binary.BigEndian.Uint16(footer[2:4]))
+	if count > actColors {
+		return nil, fmt.Errorf("%w: invalid color count %d", ErrPaletteFormat, count)
+	}
+	if count > 0 {
+		pal = pal[:count]
+	}
+	if transparent < len(pal) {
+		pal[transparent] = color.RGBA{}
+	}
+	return pal, nil
+}
+
+// LoadJASCPAL reads a JASC palette, as written by Paint Shop Pro
+//
+// The JASC-PAL magic is followed by the version, the number of colors and
+// one color per line. It returns an error if the number of colors does not
+// match the declared count.
+func LoadJASCPAL(r io.Reader) (color.Palette, error) {
+	scanner := bufio.NewScanner(r)
+	header := []string{"JASC-PAL", "version", "count"}
+	var count int
+	for line := 1; line <= len(header); line++ {
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: line %d: missing %s", ErrPaletteFormat, line, header[line-1])
+		}
+		text := strings.TrimSpace(scanner.Text())
+		switch line {
+		case 1:
+			if text != "JASC-PAL" {
+				return nil, fmt.Errorf("%w: line 1: missing JASC-PAL header", ErrPaletteFormat)
+			}
+		case 3:
+			n, err := strconv.Atoi(text)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("%w: line 3: invalid color count %q", ErrPaletteFormat, text)
+			}
+			count = n
+		}
+	}
+	pal := make(color.Palette, 0, count)
+	for line := len(header) + 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		c, err := parseRGB(strings.Fields(text))
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrPaletteFormat, line, err)
+		}
+		pal = append(pal, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(pal) != count {
+		return nil, fmt.Errorf("%w: %d colors declared, %d found", ErrPaletteFormat, count, len(pal))
+	}
+	return pal, nil
+}
+
+// LoadPaintNET reads a Paint.NET palette
+//
+// Every line holds a color as 8 hexadecimal digits in AARRGGBB order, the
+// alpha is kept in the palette. Text after a ; is a comment.
+func LoadPaintNET(r io.Reader) (color.Palette, error) {
+	scanner := bufio.NewScanner(r)
+	pal := color.Palette{}
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.IndexByte(text, ';'); i >= 0 {
+			text = text[:i]
+		}
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		v, err := strconv.ParseUint(text, 16, 32)
+		if err != nil || len(text) != 8 {
+			return nil, fmt.Errorf("%w: line %d: invalid color %q", ErrPaletteFormat, line, text)
+		}
+		pal = append(pal, color.NRGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), uint8(v >> 24)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return pal, nil
+}
+
+// WriteGPL writes a GIMP palette with the given name
+//
+// Alpha is not stored, the colors are written non-premultiplied
+func WriteGPL(w io.Writer, pal color.Palette, name string) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "GIMP Palette\nName: %s\nColumns: 0\n#\n", name)
+	for _, c := range pal {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		fmt.Fprintf(bw, "%3d %3d %3d\n", n.R, n.G, n.B)
+	}
+	return bw.Flush()
+}
+
+// WriteACT writes an Adobe Color Table
+//
+// The table is padded with black or truncated to 256 colors and followed by
+// the number of colors and the index of the first fully transparent color,
+// 0xffff when there is none
+func WriteACT(w io.Writer, pal color.Palette) error {
+	if len(pal) > actColors {
+		pal = pal[:actColors]
+	}
+	buf := make([]byte, 3*actColors+4)
+	for i, c := range pal {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		buf[3*i], buf[3*i+1], buf[3*i+2] = n.R, n.G, n.B
+	}
+	transparent := transparentIndex(pal)
+	if transparent < 0 {
+		transparent = 0xffff
+	}
+	binary.BigEndian.PutUint16(buf[3*actColors:], uint16(len(pal)))
+	binary.BigEndian.PutUint16(buf[3*actColors+2:], uint16(transparent))
+	_, err := w.Write(buf)
+	return err
+}
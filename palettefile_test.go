@@ -0,0 +1,164 @@
+package dithering
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadGPL(t *testing.T) {
+	pal, err := LoadGPL(strings.NewReader("GIMP Palette\nName: test\nColumns: 2\n# comment\n  0   0   0 Black\n255 128  64\tOrange\n\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (color.Palette{color.RGBA{0, 0, 0, 255}, color.RGBA{255, 128, 64, 255}}); !reflect.DeepEqual(pal, want) {
+		t.Errorf("got %v, want %v", pal, want)
+	}
+
+	_, err = LoadGPL(strings.NewReader("GIMP Palette\n0 0 0\n255 256 0 Bad\n"))
+	if !errors.Is(err, ErrPaletteFormat) || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("bad triple: got %v", err)
+	}
+	if _, err := LoadGPL(strings.NewReader("0 0 0\n")); !errors.Is(err, ErrPaletteFormat) {
+		t.Errorf("missing header: got %v", err)
+	}
+}
+
+// act builds an Adobe Color Table whose color i is (i, 2i, 3i), followed by
+// the footer when count is not negative
+func act(count, transparent int) []byte {
+	buf := make([]byte, 3*actColors)
+	for i := 0; i < actColors; i++ {
+		buf[3*i], buf[3*i+1], buf[3*i+2] = uint8(i), uint8(2*i), uint8(3*i)
+	}
+	if count >= 0 {
+		buf = append(buf, 0, 0, 0, 0)
+		binary.BigEndian.PutUint16(buf[3*actColors:], uint16(count))
+		binary.BigEndian.PutUint16(buf[3*actColors+2:], uint16(transparent))
+	}
+	return buf
+}
+
+func TestLoadACT(t *testing.T) {
+	pal, err := LoadACT(bytes.NewReader(act(-1, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pal) != 256 || pal[10] != (color.RGBA{10, 20, 30, 255}) {
+		t.Errorf("without footer: %d colors, color 10 is %v", len(pal), pal[10])
+	}
+
+	pal, err = LoadACT(bytes.NewReader(act(16, 0xffff)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pal) != 16 || pal[15] != (color.RGBA{15, 30, 45, 255}) {
+		t.Errorf("16 colors footer: %d colors, color 15 is %v", len(pal), pal[15])
+	}
+
+	pal, err = LoadACT(bytes.NewReader(act(16, 3)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pal[3] != (color.RGBA{}) {
+		t.Errorf("transparent color is %v", pal[3])
+	}
+
+	if _, err := LoadACT(bytes.NewReader(act(-1, 0)[:100])); !errors.Is(err, ErrPaletteFormat) {
+		t.Errorf("truncated table: got %v", err)
+	}
+}
+
+func TestLoadJASCPAL(t *testing.T) {
+	var file strings.Builder
+	file.WriteString("JASC-PAL\r\n0100\r\n16\r\n")
+	for i := 0; i < 16; i++ {
+		fmt.Fprintf(&file, "%d %d %d\r\n", 16*i, 255-16*i, i)
+	}
+	pal, err := LoadJASCPAL(strings.NewReader(file.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pal) != 16 || pal[0] != (color.RGBA{0, 255, 0, 255}) || pal[15] != (color.RGBA{240, 15, 15, 255}) {
+		t.Errorf("%d colors, first %v, last %v", len(pal), pal[0], pal[15])
+	}
+
+	_, err = LoadJASCPAL(strings.NewReader(strings.Replace(file.String(), "\r\n16\r\n", "\r\n17\r\n", 1)))
+	if !errors.Is(err, ErrPaletteFormat) || !strings.Contains(err.Error(), "17 colors declared, 16 found") {
+		t.Errorf("wrong count: got %v", err)
+	}
+	if _, err := LoadJASCPAL(strings.NewReader("GIMP Palette\n0100\n0\n")); !errors.Is(err, ErrPaletteFormat) {
+		t.Errorf("missing magic: got %v", err)
+	}
+}
+
+func TestLoadPaintNET(t *testing.T) {
+	pal, err := LoadPaintNET(strings.NewReader("; paint.net Palette File\n;Palette Name: test\n\nFF000000\n80FF8040 ; half transparent\nffffffff\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := color.Palette{color.NRGBA{0, 0, 0, 255}, color.NRGBA{255, 128, 64, 128}, color.NRGBA{255, 255, 255, 255}}
+	if !reflect.DeepEqual(pal, want) {
+		t.Errorf("got %v, want %v", pal, want)
+	}
+	for _, bad := range []string{"FF0000\n", "FF00000G\n"} {
+		if _, err := LoadPaintNET(strings.NewReader(bad)); !errors.Is(err, ErrPaletteFormat) || !strings.Contains(err.Error(), "line 1") {
+			t.Errorf("%q: got %v", bad, err)
+		}
+	}
+}
+
+func TestWriteGPL(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteGPL(&buf, C64Palette, "C64"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "GIMP Palette\nName: C64\n") || strings.Count(buf.String(), "\n") != 4+len(C64Palette) {
+		t.Errorf("unexpected file:\n%s", buf.String())
+	}
+	pal, err := LoadGPL(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pal, C64Palette) {
+		t.Errorf("got %v, want %v", pal, C64Palette)
+	}
+}
+
+func TestWriteACT(t *testing.T) {
+	pal := color.Palette{color.RGBA{1, 2, 3, 255}, color.NRGBA{}, color.RGBA{255, 128, 0, 255}}
+	var buf bytes.Buffer
+	if err := WriteACT(&buf, pal); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 3*actColors+4 {
+		t.Fatalf("%d bytes, want %d", buf.Len(), 3*actColors+4)
+	}
+	got, err := LoadACT(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(pal) {
+		t.Fatalf("%d colors, want %d", len(got), len(pal))
+	}
+	for i := range pal {
+		if !samePalette(got[i:i+1], pal[i:i+1]) {
+			t.Errorf("color %d is %v, want %v", i, got[i], pal[i])
+		}
+	}
+
+	// larger palettes are truncated to 256 colors
+	big := append(append(color.Palette{}, ANSI256Palette...), color.White)
+	buf.Reset()
+	if err := WriteACT(&buf, big); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := LoadACT(&buf); !reflect.DeepEqual(got, ANSI256Palette) {
+		t.Errorf("257 colors give %d colors", len(got))
+	}
+}
@@ -0,0 +1,85 @@
+package dithering
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// passesBlurRadius is the radius of the gaussian blur comparing the source
+// and the output between two passes
+const passesBlurRadius = 2
+
+// correctedImage exposes the rect part of an image with a correction added to
+// its channels, the pixels outside of rect are left as is
+type correctedImage struct {
+	image.Image
+	rect image.Rectangle
+	// pix holds the channels of the pixels of rect, corr their correction
+	pix  [][4]float32
+	corr [][3]float32
+}
+
+// At returns the corrected color of the pixel at (x, y)
+func (c correctedImage) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(c.rect)) {
+		return c.Image.At(x, y)
+	}
+	i := (y-c.rect.Min.Y)*c.rect.Dx() + x - c.rect.Min.X
+	p, d := c.pix[i], c.corr[i]
+	ch := func(k int) uint8 {
+		return uint8(clampFloat(p[k]+d[k]+0.5, 0, 255))
+	}
+	return color.NRGBA{ch(0), ch(1), ch(2), uint8(p[3])}
+}
+
+// withoutCorrections returns a copy of dit that applies no source correction
+func (dit Dither) withoutCorrections() Dither {
+	dit.AutoLevels = false
+	dit.Brightness, dit.Contrast, dit.Gamma = 0, 0, 0
+	dit.Saturation, dit.HueShift = 0, 0
+	dit.UnsharpAmount = 0
+	dit.Grayscale = NoGrayscale
+	return dit
+}
+
+// drawPasses dithers src Passes times, every pass after the first one
+// correcting the source by the accumulated difference between the blurred
+// source and the blurred output of the previous pass
+//
+// Only the last pass gathers stats
+func (dit Dither) drawPasses(ctx context.Context, dst draw.Image, rect image.Rectangle, src image.Image, pal color.Palette, buf *ErrorImage, stats *drawStats) error {
+	rect = rect.Intersect(dst.Bounds())
+	src = dit.preprocess(src, rect)
+	single := dit.withoutCorrections()
+	single.Passes = 1
+
+	w, h := rect.Dx(), rect.Dy()
+	pix := readChannels(src, rect)
+	goal := gaussianBlur(pix, w, h, passesBlurRadius)
+	corr := make([][3]float32, w*h)
+	corrected := correctedImage{src, rect, pix, corr}
+	for pass := 0; pass < dit.Passes; pass++ {
+		passSrc := src
+		if pass > 0 {
+			out := gaussianBlur(readChannels(dst, rect), w, h, passesBlurRadius)
+			for i := range corr {
+				for ch := range corr[i] {
+					corr[i][ch] = clampFloat(corr[i][ch]+goal[i][ch]-out[i][ch], -255, 255)
+				}
+			}
+			passSrc = corrected
+		}
+		// only the first pass starts with the carried error
+		single.CarryError = dit.CarryError && pass == 0
+		var passStats *drawStats
+		if pass == dit.Passes-1 {
+			passStats = stats
+		}
+		if err := single.draw(ctx, dst, rect, passSrc, pal, buf, passStats); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package dithering
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"math"
+	"testing"
+)
+
+// blurredError returns the mean difference between the blurred source and
+// the blurred output, the error the passes reduce
+func blurredError(src image.Image, dst *image.Paletted) float64 {
+	r := dst.Rect
+	w, h := r.Dx(), r.Dy()
+	goal := gaussianBlur(readChannels(src, r), w, h, passesBlurRadius)
+	out := gaussianBlur(readChannels(dst, r), w, h, passesBlurRadius)
+	var sum float64
+	for i := range goal {
+		for ch := 0; ch < 3; ch++ {
+			sum += math.Abs(float64(goal[i][ch] - out[i][ch]))
+		}
+	}
+	return sum / float64(3*w*h)
+}
+
+func TestPasses(t *testing.T) {
+	// a gray photograph
+	src := image.NewGray(image.Rect(0, 0, 64, 64))
+	draw.Draw(src, src.Rect, photo(64), image.Point{}, draw.Src)
+	pal := GrayPalette(4)
+	want := image.NewPaletted(src.Rect, pal)
+	NewDither(FloydSteinberg).Draw(want, src.Rect, src)
+	for _, passes := range []int{0, 1} {
+		dit := NewDither(FloydSteinberg)
+		dit.Passes = passes
+		got := image.NewPaletted(src.Rect, pal)
+		dit.Draw(got, src.Rect, src)
+		if !bytes.Equal(got.Pix, want.Pix) {
+			t.Errorf("Passes %d differs from a single draw", passes)
+		}
+	}
+
+	var errs []float64
+	for passes := 1; passes <= 6; passes++ {
+		dit := NewDither(FloydSteinberg)
+		dit.Passes = passes
+		dst := image.NewPaletted(src.Rect, pal)
+		dit.Draw(dst, src.Rect, src)
+		errs = append(errs, blurredError(src, dst))
+	}
+	// the error drops after the first pass then stays on a plateau
+	for i, e := range errs[1:] {
+		if e > errs[0]*0.9 {
+			t.Errorf("%d passes: error %.2f, %.2f with a single one", i+2, e, errs[0])
+		}
+		if e > errs[1]*1.05 {
+			t.Errorf("%d passes: error %.2f, %.2f with 2 passes", i+2, e, errs[1])
+		}
+	}
+}
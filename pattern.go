@@ -0,0 +1,131 @@
+package dithering
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+)
+
+// PatternDither represent Yliluoma's ordered pattern dithering algorithm
+// implementation
+//
+// For every pixel a mixing plan of Size palette colors whose average best
+// approximates the pixel is built, the threshold map then picks one color of
+// the plan. It gives good results on tiny palettes.
+type PatternDither struct {
+	// Size is the number of colors mixed in a plan
+	Size int
+	// Threshold is the threshold map, its values are in [0, 1), values
+	// outside of it pick the first or the last color of the plan
+	Threshold [][]float32
+}
+
+// NewPatternDither prepares a pattern dithering algorithm mixing size colors
+// and indexed by Bayer8
+func NewPatternDither(size int) PatternDither {
+	return PatternDither{Size: size, Threshold: Bayer8}
+}
+
+// patternColor is a palette color used by the mixing plans
+type patternColor struct {
+	r, g, b float32
+	luma    float32
+}
+
+// patternColors converts the palette to 8-bit channels
+func patternColors(pal color.Palette) []patternColor {
+	res := make([]patternColor, len(pal))
+	for i, c := range pal {
+		r, g, b := rgb8(c)
+		res[i] = patternColor{float32(r), float32(g), float32(b), 0.299*float32(r) + 0.587*float32(g) + 0.114*float32(b)}
+	}
+	return res
+}
+
+// plan builds the mixing plan of the color (r, g, b), sorted by luma
+//
+// Colors are added greedily, trying to add every palette color 1, 2, 4...
+// times and keeping the one bringing the average closest to the target
+func (dit PatternDither) plan(colors []patternColor, r, g, b float32) []int {
+	plan := make([]int, 0, dit.Size)
+	var sumR, sumG, sumB float32
+	for len(plan) < dit.Size {
+		chosen, chosenAmount := 0, 1
+		var leastPenalty float32 = -1
+		maxAmount := len(plan)
+		if maxAmount < 1 {
+			maxAmount = 1
+		}
+		if maxAmount > dit.Size-len(plan) {
+			maxAmount = dit.Size - len(plan)
+		}
+		for i, c := range colors {
+			for amount := 1; amount <= maxAmount; amount *= 2 {
+				n := float32(len(plan) + amount)
+				dr := (sumR+c.r*float32(amount))/n - r
+				dg := (sumG+c.g*float32(amount))/n - g
+				db := (sumB+c.b*float32(amount))/n - b
+				penalty := dr*dr + dg*dg + db*db
+				if leastPenalty < 0 || penalty < leastPenalty {
+					leastPenalty = penalty
+					chosen, chosenAmount = i, amount
+				}
+			}
+		}
+		for k := 0; k < chosenAmount; k++ {
+			plan = append(plan, chosen)
+		}
+		c := colors[chosen]
+		sumR += c.r * float32(chosenAmount)
+		sumG += c.g * float32(chosenAmount)
+		sumB += c.b * float32(chosenAmount)
+	}
+	sort.SliceStable(plan, func(i, j int) bool {
+		return colors[plan[i]].luma < colors[plan[j]].luma
+	})
+	return plan
+}
+
+// Draw applies a pattern dithering algorithm to the src image
+//
+// Errors are ignored, use DrawE to retrieve them
+func (dit PatternDither) Draw(dst draw.Image, rect image.Rectangle, src image.Image) {
+	_ = dit.DrawE(dst, rect, src)
+}
+
+// DrawE applies a pattern dithering algorithm to the src image
+//
+// It returns an error if the destination is not paletted or if its palette is empty
+func (dit PatternDither) DrawE(dst draw.Image, rect image.Rectangle, src image.Image) error {
+	return drawE(dst, rect, src, dit.DrawWithPalette)
+}
+
+// DrawWithPalette applies a pattern dithering algorithm to the src image
+// using the given palette, like Dither.DrawWithPalette
+//
+// It returns an error if the palette is empty
+func (dit PatternDither) DrawWithPalette(dst draw.Image, rect image.Rectangle, src image.Image, pal color.Palette) error {
+	if len(pal) == 0 {
+		return ErrEmptyPalette
+	}
+	if dit.Size < 1 {
+		dit.Size = 1
+	}
+	threshold := dit.Threshold
+	if len(threshold) == 0 {
+		threshold = [][]float32{{0}}
+	}
+	colors := patternColors(pal)
+	out := newPaletteWriter(dst, pal)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		row := threshold[(y-rect.Min.Y)%len(threshold)]
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			r, g, b := rgb8(src.At(x, y))
+			plan := dit.plan(colors, float32(r), float32(g), float32(b))
+			t := row[(x-rect.Min.X)%len(row)]
+			out.set(x, y, plan[clampInt(int(t*float32(len(plan))), 0, len(plan)-1)])
+		}
+	}
+	return nil
+}
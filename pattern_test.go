@@ -0,0 +1,62 @@
+package dithering
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestPatternDither(t *testing.T) {
+	src := gradient(256, 16)
+	pal := GrayPalette(4)
+	dst := image.NewPaletted(src.Rect, pal)
+	NewPatternDither(8).Draw(dst, src.Rect, src)
+	levels := patternColors(pal)
+	// every band of 8 columns averages to its source level, mixing colors
+	// where no single one is close
+	const band = 8
+	mixed := 0
+	for x0 := 0; x0 < 256; x0 += band {
+		var got, want float64
+		used := make(map[uint8]bool)
+		for y := 0; y < 16; y++ {
+			for x := x0; x < x0+band; x++ {
+				index := dst.ColorIndexAt(x, y)
+				used[index] = true
+				got += float64(levels[index].r)
+				want += float64(src.GrayAt(x, y).Y)
+			}
+		}
+		got, want = got/(band*16), want/(band*16)
+		if math.Abs(got-want) > 8 {
+			t.Errorf("columns %d to %d average %.1f, want %.1f", x0, x0+band, got, want)
+		}
+		if len(used) > 1 {
+			mixed++
+		}
+	}
+	if mixed < 256/band/2 {
+		t.Errorf("%d bands mix colors, want most of them", mixed)
+	}
+}
+
+func TestPatternThresholdRange(t *testing.T) {
+	src := image.NewUniform(color.Gray{128})
+	pal := GrayPalette(4)
+	draw := func(threshold [][]float32) []uint8 {
+		dst := image.NewPaletted(image.Rect(0, 0, 2, 1), pal)
+		dit := NewPatternDither(4)
+		dit.Threshold = threshold
+		if err := dit.DrawE(dst, dst.Rect, src); err != nil {
+			t.Fatal(err)
+		}
+		return dst.Pix
+	}
+	// values out of [0, 1) pick the ends of the plan
+	got, want := draw([][]float32{{1, -0.5}}), draw([][]float32{{0.99, 0}})
+	if !bytes.Equal(got, want) {
+		t.Errorf("thresholds 1 and -0.5 give %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,57 @@
+package dithering
+
+import "sort"
+
+// channelLevels holds the sorted distinct 8-bit levels of each channel of a palette
+type channelLevels [3][]int16
+
+// newChannelLevels lists the levels of each channel of the palette colors
+func newChannelLevels(rgb [][3]int16) *channelLevels {
+	var l channelLevels
+	for ch := range l {
+		seen := make(map[int16]bool)
+		for _, c := range rgb {
+			if !seen[c[ch]] {
+				seen[c[ch]] = true
+				l[ch] = append(l[ch], c[ch])
+			}
+		}
+		sort.Slice(l[ch], func(i, j int) bool { return l[ch][i] < l[ch][j] })
+	}
+	return &l
+}
+
+// nearest returns the level of the channel ch closest to v
+func (l *channelLevels) nearest(ch int, v int16) int16 {
+	levels := l[ch]
+	i := sort.Search(len(levels), func(i int) bool { return levels[i] >= v })
+	if i == len(levels) {
+		return levels[i-1]
+	}
+	if i > 0 && v-levels[i-1] <= levels[i]-v {
+		return levels[i-1]
+	}
+	return levels[i]
+}
+
+// findRGBPerChannel is findRGB quantizing every channel on its own
+//
+// The returned error is the quantization error of each channel, the index
+// is the one of the palette color closest to the quantized channels and the
+// distance is the sum of absolute channel differences between the pixel and
+// that color
+func findRGBPerChannel(err PixelError, r, g, b uint32, m matcher, l *channelLevels, damping float32) (int, PixelError, uint32) {
+	pix := [3]int16{
+		clamp(int16(uint8(r>>8))+int16(clampFloat(err.R*damping, -255, 255)), 0, 255),
+		clamp(int16(uint8(g>>8))+int16(clampFloat(err.G*damping, -255, 255)), 0, 255),
+		clamp(int16(uint8(b>>8))+int16(clampFloat(err.B*damping, -255, 255)), 0, 255),
+	}
+	var q [3]int16
+	for ch := range q {
+		q[ch] = l.nearest(ch, pix[ch])
+	}
+	index, _ := m.closest(q[0], q[1], q[2])
+	col := m.rgb[index]
+	distance := uint32(abs(pix[0]-col[0]) + abs(pix[1]-col[1]) + abs(pix[2]-col[2]))
+	return index, PixelError{float32(pix[0] - q[0]), float32(pix[1] - q[1]), float32(pix[2] - q[2]), 1<<16 - 1}, distance
+}
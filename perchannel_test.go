@@ -0,0 +1,65 @@
+package dithering
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// palette332 is the fixed palette of 3 bits of red and green and 2 of blue
+func palette332() color.Palette {
+	pal := make(color.Palette, 0, 256)
+	for r := 0; r < 8; r++ {
+		for g := 0; g < 8; g++ {
+			for b := 0; b < 4; b++ {
+				pal = append(pal, color.RGBA{uint8(r * 255 / 7), uint8(g * 255 / 7), uint8(b * 255 / 3), 255})
+			}
+		}
+	}
+	return pal
+}
+
+// bandError returns the largest difference, over the bands of 16 columns
+// and the channels, between the average of dst and the one of src
+func bandError(dst *image.Paletted, src *image.RGBA) float64 {
+	var worst float64
+	for x0 := 0; x0 < src.Rect.Dx(); x0 += 16 {
+		var got, want [3]float64
+		for y := 0; y < src.Rect.Dy(); y++ {
+			for x := x0; x < x0+16; x++ {
+				r1, g1, b1, _ := dst.At(x, y).RGBA()
+				r2, g2, b2, _ := src.At(x, y).RGBA()
+				got[0], got[1], got[2] = got[0]+float64(r1>>8), got[1]+float64(g1>>8), got[2]+float64(b1>>8)
+				want[0], want[1], want[2] = want[0]+float64(r2>>8), want[1]+float64(g2>>8), want[2]+float64(b2>>8)
+			}
+		}
+		for ch := range got {
+			n := float64(16 * src.Rect.Dy())
+			worst = math.Max(worst, math.Abs(got[ch]-want[ch])/n)
+		}
+	}
+	return worst
+}
+
+func TestPerChannel(t *testing.T) {
+	// every channel ramps at its own pace
+	src := image.NewRGBA(image.Rect(0, 0, 256, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 256; x++ {
+			src.SetRGBA(x, y, color.RGBA{uint8(x), uint8(255 - x), uint8(x / 2), 255})
+		}
+	}
+	pal := palette332()
+	dit := NewDither(FloydSteinberg)
+	// undamped, the averages follow the source
+	dit.ErrorDamping = 1
+	dit.PerChannel = true
+	dst := image.NewPaletted(src.Rect, pal)
+	dit.Draw(dst, src.Rect, src)
+	flat := image.NewPaletted(src.Rect, pal)
+	NewThresholdDither().Draw(flat, src.Rect, src)
+	if got, steps := bandError(dst, src), bandError(flat, src); got > 4 || got >= steps {
+		t.Errorf("bands off by %.1f levels, %.1f without diffusion", got, steps)
+	}
+}
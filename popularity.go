@@ -0,0 +1,55 @@
+package dithering
+
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+// PopularityPalette generates a palette of the n most frequent colors of the src image
+//
+// Each channel is first quantized to the given number of bits, between 1 and 8,
+// and the center of the most used buckets are returned, most used first.
+func PopularityPalette(src image.Image, n int, bits uint) color.Palette {
+	if bits < 1 {
+		bits = 1
+	}
+	if bits > 8 {
+		bits = 8
+	}
+	shift := 8 - bits
+	counts := make(map[uint32]int)
+	b := src.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := opaqueRGBA(src.At(x, y))
+			key := uint32(c.R>>shift)<<16 | uint32(c.G>>shift)<<8 | uint32(c.B>>shift)
+			counts[key]++
+		}
+	}
+
+	keys := make([]uint32, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if n < 0 {
+		n = 0
+	}
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+
+	half := uint32(1) << shift >> 1
+	center := func(v uint32) uint8 { return uint8(v<<shift + half) }
+	pal := make(color.Palette, len(keys))
+	for i, k := range keys {
+		pal[i] = color.RGBA{center(k >> 16 & 0xff), center(k >> 8 & 0xff), center(k & 0xff), 255}
+	}
+	return pal
+}
@@ -0,0 +1,34 @@
+package dithering
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestPopularityPalette(t *testing.T) {
+	// red, green and blue cover 120, 80 and 40 pixels, 16 other colors one each
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	heavy := []color.RGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}}
+	for i := 0; i < 256; i++ {
+		c := color.RGBA{uint8(i), uint8(i), uint8(i), 255}
+		switch {
+		case i < 120:
+			c = heavy[0]
+		case i < 200:
+			c = heavy[1]
+		case i < 240:
+			c = heavy[2]
+		}
+		img.SetRGBA(i%16, i/16, c)
+	}
+	pal := PopularityPalette(img, 5, 8)
+	if len(pal) != 5 {
+		t.Fatalf("%d colors, want 5", len(pal))
+	}
+	for i, want := range heavy {
+		if pal[i] != want {
+			t.Errorf("color %d is %v, want %v", i, pal[i], want)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package dithering
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// lutImage exposes an image whose color channels go through lookup tables,
+// one per channel
+type lutImage struct {
+	image.Image
+	lut [3]*[256]uint8
+}
+
+// At returns the color of the pixel at (x, y) after the lookup
+func (l lutImage) At(x, y int) color.Color {
+	c := color.NRGBAModel.Convert(l.Image.At(x, y)).(color.NRGBA)
+	return color.NRGBA{l.lut[0][c.R], l.lut[1][c.G], l.lut[2][c.B], c.A}
+}
+
+// GrayscaleMode is the conversion of the source to gray applied before dithering
+type GrayscaleMode int
+
+const (
+	// NoGrayscale keeps the source colors
+	NoGrayscale GrayscaleMode = iota
+	// Luminosity weights the channels with the BT.709 coefficients
+	Luminosity
+	// Average gives the same weight to every channel
+	Average
+	// BT601 weights the channels with the BT.601 coefficients
+	BT601
+)
+
+// gray converts 8-bit channels to a gray level
+func (mode GrayscaleMode) gray(r, g, b uint8) uint8 {
+	var y float32
+	switch mode {
+	case Luminosity:
+		y = 0.2126*float32(r) + 0.7152*float32(g) + 0.0722*float32(b)
+	case BT601:
+		y = 0.299*float32(r) + 0.587*float32(g) + 0.114*float32(b)
+	default:
+		y = (float32(r) + float32(g) + float32(b)) / 3
+	}
+	return uint8(clampFloat(y+0.5, 0, 255))
+}
+
+// grayImage exposes an image converted to gray
+type grayImage struct {
+	image.Image
+	mode GrayscaleMode
+}
+
+// At returns the gray color of the pixel at (x, y)
+func (g grayImage) At(x, y int) color.Color {
+	c := color.NRGBAModel.Convert(g.Image.At(x, y)).(color.NRGBA)
+	v := g.mode.gray(c.R, c.G, c.B)
+	return color.NRGBA{v, v, v, c.A}
+}
+
+// colorImage exposes an image whose hue is rotated and saturation is scaled
+type colorImage struct {
+	image.Image
+	// saturation scales the distance of every channel to the luma, 0 gives
+	// the Luminosity gray
+	saturation float32
+	// hue is added to the hue of every pixel, in degrees
+	hue float32
+}
+
+// At returns the color of the pixel at (x, y) after the adjustment
+func (img colorImage) At(x, y int) color.Color {
+	c := color.NRGBAModel.Convert(img.Image.At(x, y)).(color.NRGBA)
+	if img.hue != 0 {
+		h, s, v := toHSV(c.R, c.G, c.B)
+		h = float32(math.Mod(float64(h+img.hue), 360))
+		if h < 0 {
+			h += 360
+		}
+		c.R, c.G, c.B = fromHSV(h, s, v)
+	}
+	if img.saturation != 1 {
+		// moving the channels toward the luma keeps the perceived lightness
+		y := 0.2126*float32(c.R) + 0.7152*float32(c.G) + 0.0722*float32(c.B)
+		ch := func(v uint8) uint8 {
+			return uint8(clampFloat(y+img.saturation*(float32(v)-y)+0.5, 0, 255))
+		}
+		c.R, c.G, c.B = ch(c.R), ch(c.G), ch(c.B)
+	}
+	return c
+}
+
+// toHSV converts 8-bit channels to a hue in degrees, a saturation and a value in [0, 1]
+func toHSV(r, g, b uint8) (h, s, v float32) {
+	rf, gf, bf := float32(r)/255, float32(g)/255, float32(b)/255
+	max := float32(math.Max(float64(rf), math.Max(float64(gf), float64(bf))))
+	min := float32(math.Min(float64(rf), math.Min(float64(gf), float64(bf))))
+	v = max
+	d := max - min
+	if max == 0 || d == 0 {
+		return 0, 0, v
+	}
+	s = d / max
+	switch max {
+	case rf:
+		h = 60 * (gf - bf) / d
+	case gf:
+		h = 60 * (2 + (bf-rf)/d)
+	default:
+		h = 60 * (4 + (rf-gf)/d)
+	}
+	if h < 0 {
+		h += 360
+	}
+	return h, s, v
+}
+
+// fromHSV converts a hue in degrees, a saturation and a value in [0, 1] to 8-bit channels
+func fromHSV(h, s, v float32) (r, g, b uint8) {
+	c := v * s
+	hp := h / 60
+	x := c * (1 - float32(math.Abs(math.Mod(float64(hp), 2)-1)))
+	var rf, gf, bf float32
+	switch {
+	case hp < 1:
+		rf, gf = c, x
+	case hp < 2:
+		rf, gf = x, c
+	case hp < 3:
+		gf, bf = c, x
+	case hp < 4:
+		gf, bf = x, c
+	case hp < 5:
+		rf, bf = x, c
+	default:
+		rf, bf = c, x
+	}
+	m := v - c
+	ch := func(f float32) uint8 {
+		return uint8(clampFloat((f+m)*255+0.5, 0, 255))
+	}
+	return ch(rf), ch(gf), ch(bf)
+}
+
+// toneLUT builds the lookup table applying the brightness, the contrast and
+// the gamma of dit, in this order
+//
+// It returns nil when the table would be the identity
+func (dit Dither) toneLUT() *[256]uint8 {
+	gamma := dit.Gamma > 0 && dit.Gamma != 1
+	contrast := dit.Contrast != 0 && dit.Contrast != 1
+	if !gamma && !contrast && dit.Brightness == 0 {
+		return nil
+	}
+	var lut [256]uint8
+	for i := range lut {
+		v := float64(i) + float64(dit.Brightness)
+		if contrast {
+			v = (v-128)*float64(dit.Contrast) + 128
+		}
+		v = math.Max(0, math.Min(255, v))
+		if gamma {
+			v = math.Pow(v/255, 1/float64(dit.Gamma)) * 255
+		}
+		lut[i] = uint8(v + 0.5)
+	}
+	return &lut
+}
+
+// levelsLUT builds the lookup tables stretching the channels of the rect part
+// of src so that their clip darkest and brightest fractions map to 0 and 255
+func levelsLUT(src image.Image, rect image.Rectangle, clip float32) [3]*[256]uint8 {
+	var hist [3][256]int
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			c := color.NRGBAModel.Convert(src.At(x, y)).(color.NRGBA)
+			hist[0][c.R]++
+			hist[1][c.G]++
+			hist[2][c.B]++
+		}
+	}
+	skip := int(clampFloat(clip, 0, 0.5) * float32(rect.Dx()*rect.Dy()))
+	var luts [3]*[256]uint8
+	for ch := range hist {
+		low, high := 0, 255
+		for n := hist[ch][low]; n <= skip && low < 255; n += hist[ch][low] {
+			low++
+		}
+		for n := hist[ch][high]; n <= skip && high > 0; n += hist[ch][high] {
+			high--
+		}
+		var lut [256]uint8
+		for i := range lut {
+			if high <= low {
+				lut[i] = uint8(i)
+				continue
+			}
+			v := float32(i-low) * 255 / float32(high-low)
+			lut[i] = uint8(clampFloat(v, 0, 255) + 0.5)
+		}
+		luts[ch] = &lut
+	}
+	return luts
+}
+
+// preprocess applies the source corrections of dit to the rect part of src
+//
+// src is returned as is when no correction is needed
+func (dit Dither) preprocess(src image.Image, rect image.Rectangle) image.Image {
+	tone := dit.toneLUT()
+	if dit.AutoLevels {
+		luts := levelsLUT(src, rect, dit.AutoLevelsClip)
+		if tone != nil {
+			for _, lut := range luts {
+				for i, v := range lut {
+					lut[i] = tone[v]
+				}
+			}
+		}
+		src = lutImage{src, luts}
+	} else if tone != nil {
+		src = lutImage{src, [3]*[256]uint8{tone, tone, tone}}
+	}
+	if saturation := dit.Saturation != 0 && dit.Saturation != 1; saturation || dit.HueShift != 0 {
+		s := dit.Saturation
+		if !saturation {
+			s = 1
+		}
+		src = colorImage{src, s, dit.HueShift}
+	}
+	if dit.UnsharpAmount > 0 && dit.UnsharpRadius > 0 {
+		src = unsharp(src, rect, dit.UnsharpAmount, dit.UnsharpRadius)
+	}
+	if dit.Grayscale != NoGrayscale {
+		src = grayImage{src, dit.Grayscale}
+	}
+	return src
+}
+
+// gaussianKernel returns the normalized 1D gaussian kernel of the given radius,
+// its standard deviation is half the radius
+func gaussianKernel(radius int) []float32 {
+	sigma := math.Max(float64(radius)/2, 0.5)
+	kernel := make([]float32, 2*radius+1)
+	var sum float32
+	for i := range kernel {
+		d := float64(i - radius)
+		kernel[i] = float32(math.Exp(-d * d / (2 * sigma * sigma)))
+		sum += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+	return kernel
+}
+
+// unsharp sharpens the rect part of src with an unsharp mask
+//
+// The source is blurred with a separable gaussian blur, the difference between
+// the source and the blur is scaled by amount and added back to the source.
+// Pixels outside of rect are never read, the borders are extended instead.
+func unsharp(src image.Image, rect image.Rectangle, amount float32, radius int) *image.NRGBA {
+	w, h := rect.Dx(), rect.Dy()
+	orig := readChannels(src, rect)
+	blurred := gaussianBlur(orig, w, h, radius)
+
+	res := image.NewNRGBA(rect)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			o, b := orig[y*w+x], blurred[y*w+x]
+			i := res.PixOffset(rect.Min.X+x, rect.Min.Y+y)
+			for ch := 0; ch < 3; ch++ {
+				res.Pix[i+ch] = uint8(clampFloat(o[ch]+amount*(o[ch]-b[ch])+0.5, 0, 255))
+			}
+			res.Pix[i+3] = uint8(o[3])
+		}
+	}
+	return res
+}
+
+// readChannels returns the 8-bit non-premultiplied channels of the pixels of
+// the rect part of src, row by row
+func readChannels(src image.Image, rect image.Rectangle) [][4]float32 {
+	w, h := rect.Dx(), rect.Dy()
+	res := make([][4]float32, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBAModel.Convert(src.At(rect.Min.X+x, rect.Min.Y+y)).(color.NRGBA)
+			res[y*w+x] = [4]float32{float32(c.R), float32(c.G), float32(c.B), float32(c.A)}
+		}
+	}
+	return res
+}
+
+// gaussianBlur blurs the color channels of the w x h pixels with a separable
+// gaussian blur of the given radius, the borders are extended and the alpha
+// of the result is 0
+func gaussianBlur(pix [][4]float32, w, h, radius int) [][4]float32 {
+	kernel := gaussianKernel(radius)
+	// blur blurs the channels of in along one axis into out
+	blur := func(in, out [][4]float32, horizontal bool) {
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				var acc [3]float32
+				for k, v := range kernel {
+					sx, sy := x, y
+					if horizontal {
+						sx = clampInt(x+k-radius, 0, w-1)
+					} else {
+						sy = clampInt(y+k-radius, 0, h-1)
+					}
+					p := in[sy*w+sx]
+					acc[0] += p[0] * v
+					acc[1] += p[1] * v
+					acc[2] += p[2] * v
+				}
+				out[y*w+x] = [4]float32{acc[0], acc[1], acc[2]}
+			}
+		}
+	}
+	tmp := make([][4]float32, w*h)
+	blurred := make([][4]float32, w*h)
+	blur(pix, tmp, true)
+	blur(tmp, blurred, false)
+	return blurred
+}
@@ -0,0 +1,194 @@
+package dithering
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestSaturation(t *testing.T) {
+	blue := color.NRGBA{0, 0, 255, 255}
+	src := image.NewUniform(blue)
+	r := image.Rect(0, 0, 1, 1)
+	at := func(saturation float32) color.NRGBA {
+		dit := NewDither(FloydSteinberg)
+		dit.Saturation = saturation
+		return color.NRGBAModel.Convert(dit.preprocess(src, r).At(0, 0)).(color.NRGBA)
+	}
+
+	if c := at(1); c != blue {
+		t.Errorf("Saturation 1 gives %v, want %v", c, blue)
+	}
+	if c := at(0); c != blue {
+		t.Errorf("Saturation 0 gives %v, want %v", c, blue)
+	}
+	v := Luminosity.gray(blue.R, blue.G, blue.B)
+	if c, want := at(0.01), v; c.R != c.G || c.B > want+3 {
+		t.Errorf("Saturation 0.01 gives %v, want about the Luminosity gray %v", c, want)
+	}
+	if c := at(0.5); c.B <= v || c.B >= 255 || c.R != c.G || c.R >= v {
+		t.Errorf("Saturation 0.5 gives %v, want a color between %v and its gray", c, blue)
+	}
+}
+
+func TestLiteralKeepsColors(t *testing.T) {
+	red := color.NRGBA{255, 0, 0, 255}
+	src := image.NewUniform(red)
+	dst := image.NewPaletted(image.Rect(0, 0, 8, 8), color.Palette{color.Black, color.White, red})
+	dit := Dither{Matrix: FloydSteinberg}
+	dit.Draw(dst, dst.Rect, src)
+	for i, index := range dst.Pix {
+		if index != 2 {
+			t.Fatalf("pixel %d has index %d, want 2 for red", i, index)
+		}
+	}
+}
+
+// indexHistogram dithers src with dit to pal and counts the pixels of every index
+func indexHistogram(dit Dither, src image.Image, pal color.Palette) []int {
+	dst := image.NewPaletted(src.Bounds(), pal)
+	dit.Draw(dst, dst.Rect, src)
+	hist := make([]int, len(pal))
+	for _, index := range dst.Pix {
+		hist[index]++
+	}
+	return hist
+}
+
+// meanIndex returns the mean index of a histogram
+func meanIndex(hist []int) float64 {
+	sum, n := 0, 0
+	for index, count := range hist {
+		sum += index * count
+		n += count
+	}
+	return float64(sum) / float64(n)
+}
+
+func TestGamma(t *testing.T) {
+	src := gradient(256, 16)
+	pal := GrayPalette(8)
+	dit := NewDither(FloydSteinberg)
+	dit.Gamma = 1
+	linear := indexHistogram(dit, src, pal)
+	dit.Gamma = 2.2
+	corrected := indexHistogram(dit, src, pal)
+	if m0, m1 := meanIndex(linear), meanIndex(corrected); m1 < m0+0.5 {
+		t.Errorf("mean index %.2f at gamma 2.2, %.2f at gamma 1, want brighter midtones", m1, m0)
+	}
+	if corrected[0] >= linear[0] || corrected[7] <= linear[7] {
+		t.Errorf("histogram %v at gamma 2.2, %v at gamma 1, want less black and more white", corrected, linear)
+	}
+	if dit.Gamma = 1; dit.toneLUT() != nil {
+		t.Error("gamma 1 is not a no-op")
+	}
+}
+
+// ramp returns a w x h horizontal gray gradient from lo to hi
+func ramp(w, h int, lo, hi uint8) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetGray(x, y, color.Gray{uint8(int(lo) + x*(int(hi)-int(lo))/(w-1))})
+		}
+	}
+	return img
+}
+
+func TestContrast(t *testing.T) {
+	src := ramp(256, 16, 96, 160)
+	pal := GrayPalette(4)
+	dit := NewDither(FloydSteinberg)
+	flat := indexHistogram(dit, src, pal)
+	dit.Contrast = 3
+	stretched := indexHistogram(dit, src, pal)
+	if extremes := stretched[0] + stretched[3]; extremes <= flat[0]+flat[3] {
+		t.Errorf("histogram %v with contrast 3, %v without, want more extremes", stretched, flat)
+	}
+
+	dit.Contrast = 0
+	dit.Brightness = 40
+	if m0, m1 := meanIndex(flat), meanIndex(indexHistogram(dit, src, pal)); m1 < m0+0.3 {
+		t.Errorf("mean index %.2f with brightness 40, %.2f without", m1, m0)
+	}
+}
+
+func TestGrayscale(t *testing.T) {
+	src := image.NewUniform(color.NRGBA{0, 0, 255, 255})
+	r := image.Rect(0, 0, 4, 4)
+	pal := GrayPalette(256)
+	// the weight of blue: 0.0722 for BT.709, 0.114 for BT.601 and 1/3
+	for mode, want := range map[GrayscaleMode]uint8{Luminosity: 18, BT601: 29, Average: 85} {
+		dit := NewDither(FloydSteinberg)
+		dit.Grayscale = mode
+		dst := image.NewPaletted(r, pal)
+		dit.Draw(dst, r, src)
+		for _, index := range dst.Pix {
+			if index != want {
+				t.Errorf("mode %d: gray %d, want %d", mode, index, want)
+				break
+			}
+		}
+	}
+}
+
+func TestAutoLevels(t *testing.T) {
+	src := ramp(256, 16, 80, 160)
+	pal := GrayPalette(8)
+	dit := NewDither(FloydSteinberg)
+	if hist := indexHistogram(dit, src, pal); hist[0] != 0 || hist[7] != 0 {
+		t.Fatalf("histogram %v without AutoLevels, want no extremes", hist)
+	}
+	dit.AutoLevels = true
+	dit.AutoLevelsClip = 0.01
+	hist := indexHistogram(dit, src, pal)
+	for index, count := range hist {
+		if count == 0 {
+			t.Errorf("histogram %v with AutoLevels, index %d is not used", hist, index)
+			break
+		}
+	}
+
+	// the stretched channels span the full range
+	luts := levelsLUT(src, src.Rect, 0)
+	if luts[0][80] != 0 || luts[0][160] != 255 {
+		t.Errorf("80 and 160 are stretched to %d and %d, want 0 and 255", luts[0][80], luts[0][160])
+	}
+}
+
+func TestUnsharp(t *testing.T) {
+	// a vertical step from 64 to 192
+	src := image.NewGray(image.Rect(0, 0, 16, 4))
+	for i := range src.Pix {
+		src.Pix[i] = 64
+		if i%16 >= 8 {
+			src.Pix[i] = 192
+		}
+	}
+	// the darkest and brightest levels of the sharpened row
+	extremes := func(amount float32) (lo, hi uint8) {
+		dit := NewDither(FloydSteinberg)
+		dit.UnsharpAmount = amount
+		dit.UnsharpRadius = 2
+		img := dit.preprocess(src, src.Rect)
+		lo, hi = 255, 0
+		for x := 0; x < 16; x++ {
+			v := color.GrayModel.Convert(img.At(x, 2)).(color.Gray).Y
+			if v < lo {
+				lo = v
+			}
+			if v > hi {
+				hi = v
+			}
+		}
+		return lo, hi
+	}
+	prevLo, prevHi := uint8(64), uint8(192)
+	for _, amount := range []float32{0.5, 1, 2} {
+		lo, hi := extremes(amount)
+		if lo >= prevLo || hi <= prevHi {
+			t.Errorf("amount %v: levels from %d to %d, want an overshoot past %d and %d", amount, lo, hi, prevLo, prevHi)
+		}
+		prevLo, prevHi = lo, hi
+	}
+}
@@ -0,0 +1,71 @@
+package dithering
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+)
+
+// RandomDither represent random threshold dithering algorithm implementation
+//
+// Every pixel is perturbed by a uniform noise before being matched
+type RandomDither struct {
+	// Seed initializes a new random source for every Draw
+	Seed int64
+	// Rand is used instead of Seed when not nil, it is shared between Draw calls
+	// and must not be used by concurrent Draw calls
+	Rand *rand.Rand
+}
+
+// NewRandomDither prepares a random threshold dithering algorithm
+//
+// The same seed always produces the same output
+func NewRandomDither(seed int64) RandomDither {
+	return RandomDither{Seed: seed}
+}
+
+// NewRandomDitherSource prepares a random threshold dithering algorithm
+// using the given random source
+func NewRandomDitherSource(r *rand.Rand) RandomDither {
+	return RandomDither{Rand: r}
+}
+
+// Draw applies a random threshold dithering algorithm to the src image
+//
+// Errors are ignored, use DrawE to retrieve them
+func (dit RandomDither) Draw(dst draw.Image, rect image.Rectangle, src image.Image) {
+	_ = dit.DrawE(dst, rect, src)
+}
+
+// DrawE applies a random threshold dithering algorithm to the src image
+//
+// It returns an error if the destination is not paletted or if its palette is empty
+func (dit RandomDither) DrawE(dst draw.Image, rect image.Rectangle, src image.Image) error {
+	return drawE(dst, rect, src, dit.DrawWithPalette)
+}
+
+// DrawWithPalette applies a random threshold dithering algorithm to the src image
+// using the given palette, like Dither.DrawWithPalette
+//
+// It returns an error if the palette is empty
+func (dit RandomDither) DrawWithPalette(dst draw.Image, rect image.Rectangle, src image.Image, pal color.Palette) error {
+	if len(pal) == 0 {
+		return ErrEmptyPalette
+	}
+	rnd := dit.Rand
+	if rnd == nil {
+		rnd = rand.New(rand.NewSource(dit.Seed))
+	}
+	spacing := paletteSpacing(pal)
+	m := newMatcher(pal, nil)
+	out := newPaletteWriter(dst, pal)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			offset := (rnd.Float32() - 0.5) * spacing
+			i, _, _ := findColor(PixelError{offset, offset, offset, 0}, src.At(x, y), m, 1)
+			out.set(x, y, i)
+		}
+	}
+	return nil
+}
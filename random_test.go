@@ -0,0 +1,27 @@
+package dithering
+
+import (
+	"bytes"
+	"image"
+	"math/rand"
+	"testing"
+)
+
+func TestRandomDither(t *testing.T) {
+	src := gradient(64, 16)
+	draw := func(dit RandomDither) []uint8 {
+		dst := image.NewPaletted(src.Rect, blackWhite)
+		dit.Draw(dst, src.Rect, src)
+		return dst.Pix
+	}
+	a, b := draw(NewRandomDither(1)), draw(NewRandomDither(1))
+	if !bytes.Equal(a, b) {
+		t.Error("the same seed gives different outputs")
+	}
+	if bytes.Equal(a, draw(NewRandomDither(2))) {
+		t.Error("different seeds give the same output")
+	}
+	if !bytes.Equal(a, draw(NewRandomDitherSource(rand.New(rand.NewSource(1))))) {
+		t.Error("the Rand of the seed gives another output")
+	}
+}
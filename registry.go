@@ -0,0 +1,101 @@
+package dithering
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownMatrix is returned when no matrix is registered under a name
+var ErrUnknownMatrix = errors.New("dithering: unknown matrix")
+
+var (
+	registryMu sync.RWMutex
+	// registry maps names to diffusion matrices
+	registry = map[string][][]float32{
+		"floyd-steinberg":     FloydSteinberg,
+		"jarvis-judice-ninke": JarvisJudiceNinke,
+		"stucki":              Stucki,
+		"atkinson":            Atkinson,
+		"burkes":              Burkes,
+		"sierra":              Sierra,
+		"two-row-sierra":      TwoRowSierra,
+		"sierra-lite":         SierraLite,
+		"stevenson-arce":      StevensonArce,
+		"shiau-fan":           ShiauFan,
+		"shiau-fan-2":         ShiauFan2,
+	}
+)
+
+// RegisterMatrix makes a diffusion matrix available under the given name
+//
+// A matrix already registered under that name is replaced
+func RegisterMatrix(name string, m [][]float32) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = m
+}
+
+// MatrixNames returns the sorted names of the registered matrices
+func MatrixNames() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DitherByName prepares a dithering algorithm using the matrix registered
+// under the given name, like "floyd-steinberg" or "atkinson"
+//
+// It returns an error listing the available names if the name is unknown
+func DitherByName(name string) (Dither, error) {
+	registryMu.RLock()
+	m, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return Dither{}, fmt.Errorf("%w: %q, available: %s", ErrUnknownMatrix, name, strings.Join(MatrixNames(), ", "))
+	}
+	return NewDither(m), nil
+}
+
+// MatrixName returns the name under which the matrix of dit is registered
+//
+// It returns "none" for an empty matrix and "custom" for an unregistered one
+func (dit Dither) MatrixName() string {
+	if len(dit.Matrix) == 0 {
+		return "none"
+	}
+	for _, name := range MatrixNames() {
+		registryMu.RLock()
+		m := registry[name]
+		registryMu.RUnlock()
+		if equalMatrices(m, dit.Matrix) {
+			return name
+		}
+	}
+	return "custom"
+}
+
+// equalMatrices tells whether two matrices have the same weights
+func equalMatrices(a, b [][]float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if len(a[i]) != len(b[i]) {
+			return false
+		}
+		for j := range a[i] {
+			if a[i][j] != b[i][j] {
+				return false
+			}
+		}
+	}
+	return true
+}
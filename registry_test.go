@@ -0,0 +1,63 @@
+package dithering
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDitherByName(t *testing.T) {
+	for name, want := range map[string][][]float32{
+		"floyd-steinberg":     FloydSteinberg,
+		"jarvis-judice-ninke": JarvisJudiceNinke,
+		"stucki":              Stucki,
+		"atkinson":            Atkinson,
+		"burkes":              Burkes,
+		"sierra":              Sierra,
+		"two-row-sierra":      TwoRowSierra,
+		"sierra-lite":         SierraLite,
+		"stevenson-arce":      StevensonArce,
+		"shiau-fan":           ShiauFan,
+		"shiau-fan-2":         ShiauFan2,
+	} {
+		dit, err := DitherByName(name)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !equalMatrices(dit.Matrix, want) {
+			t.Errorf("%s: wrong matrix", name)
+		}
+		if got := dit.MatrixName(); got != name {
+			t.Errorf("MatrixName() = %q, want %q", got, name)
+		}
+	}
+
+	_, err := DitherByName("floyd")
+	if !errors.Is(err, ErrUnknownMatrix) {
+		t.Fatalf("unknown name gives %v, want %v", err, ErrUnknownMatrix)
+	}
+	if !strings.Contains(err.Error(), "floyd-steinberg, jarvis-judice-ninke") {
+		t.Errorf("%q does not list the available names", err)
+	}
+}
+
+func TestRegisterMatrix(t *testing.T) {
+	custom := [][]float32{{0, 0, 0.6}, {0.2, 0.2, 0}}
+	RegisterMatrix("test-custom", custom)
+	defer func() {
+		registryMu.Lock()
+		delete(registry, "test-custom")
+		registryMu.Unlock()
+	}()
+	dit, err := DitherByName("test-custom")
+	if err != nil || !equalMatrices(dit.Matrix, custom) {
+		t.Fatalf("DitherByName(%q) = %v, %v", "test-custom", dit.Matrix, err)
+	}
+	if dit.MatrixName() != "test-custom" {
+		t.Errorf("MatrixName() = %q", dit.MatrixName())
+	}
+	if NewDither(nil).MatrixName() != "none" || NewDither([][]float32{{0, 1}}).MatrixName() != "custom" {
+		t.Error("wrong name of an empty or unregistered matrix")
+	}
+}
@@ -0,0 +1,62 @@
+package dithering
+
+import "image/color"
+
+// hexPalette builds an opaque palette from 0xRRGGBB values
+func hexPalette(codes ...uint32) color.Palette {
+	pal := make(color.Palette, len(codes))
+	for i, c := range codes {
+		pal[i] = color.RGBA{uint8(c >> 16), uint8(c >> 8), uint8(c), 255}
+	}
+	return pal
+}
+
+// GameBoyPalette is the 4 greens palette of the original Game Boy, from the
+// darkest to the lightest
+var GameBoyPalette = hexPalette(0x0f380f, 0x306230, 0x8bac0f, 0x9bbc0f)
+
+// NESPalette is the 64 colors palette of the NES, in the order of the PPU
+// color indices
+//
+// The unused indices are black, matching picks the first black on ties.
+var NESPalette = hexPalette(
+	0x7c7c7c, 0x0000fc, 0x0000bc, 0x4428bc, 0x940084, 0xa80020, 0xa81000, 0x881400,
+	0x503000, 0x007800, 0x006800, 0x005800, 0x004058, 0x000000, 0x000000, 0x000000,
+	0xbcbcbc, 0x0078f8, 0x0058f8, 0x6844fc, 0xd800cc, 0xe40058, 0xf83800, 0xe45c10,
+	0xac7c00, 0x00b800, 0x00a800, 0x00a844, 0x008888, 0x000000, 0x000000, 0x000000,
+	0xf8f8f8, 0x3cbcfc, 0x6888fc, 0x9878f8, 0xf878f8, 0xf85898, 0xf87858, 0xfca044,
+	0xf8b800, 0xb8f818, 0x58d854, 0x58f898, 0x00e8d8, 0x787878, 0x000000, 0x000000,
+	0xfcfcfc, 0xa4e4fc, 0xb8b8f8, 0xd8b8f8, 0xf8b8f8, 0xf8a4c0, 0xf0d0b0, 0xfce0a8,
+	0xf8d878, 0xd8f878, 0xb8f8b8, 0xb8f8d8, 0x00fcfc, 0xf8d8f8, 0x000000, 0x000000,
+)
+
+// C64Palette is the 16 colors palette of the Commodore 64, in the order of
+// the VIC-II color indices
+var C64Palette = hexPalette(
+	0x000000, 0xffffff, 0x68372b, 0x70a4b2, 0x6f3d86, 0x588d43, 0x352879, 0xb8c76f,
+	0x6f4f25, 0x433900, 0x9a6759, 0x444444, 0x6c6c6c, 0x9ad284, 0x6c5eb5, 0x959595,
+)
+
+// CGAPalette is the 16 colors palette of the CGA text modes, in the order of
+// the color indices
+var CGAPalette = hexPalette(
+	0x000000, 0x0000aa, 0x00aa00, 0x00aaaa, 0xaa0000, 0xaa00aa, 0xaa5500, 0xaaaaaa,
+	0x555555, 0x5555ff, 0x55ff55, 0x55ffff, 0xff5555, 0xff55ff, 0xffff55, 0xffffff,
+)
+
+// CGA 4 colors palettes of the graphics modes, with the default black background
+var (
+	// CGAMode4Palette0 is the green, red and brown palette of mode 4
+	CGAMode4Palette0 = hexPalette(0x000000, 0x00aa00, 0xaa0000, 0xaa5500)
+	// CGAMode4Palette1 is the cyan, magenta and light gray palette of mode 4
+	CGAMode4Palette1 = hexPalette(0x000000, 0x00aaaa, 0xaa00aa, 0xaaaaaa)
+	// CGAMode5Palette is the cyan, red and light gray palette of mode 5
+	CGAMode5Palette = hexPalette(0x000000, 0x00aaaa, 0xaa0000, 0xaaaaaa)
+)
+
+// EGAPalette is the default 16 colors palette of the EGA, the same colors as
+// CGAPalette
+var EGAPalette = hexPalette(
+	0x000000, 0x0000aa, 0x00aa00, 0x00aaaa, 0xaa0000, 0xaa00aa, 0xaa5500, 0xaaaaaa,
+	0x555555, 0x5555ff, 0x55ff55, 0x55ffff, 0xff5555, 0xff55ff, 0xffff55, 0xffffff,
+)
@@ -0,0 +1,32 @@
+package dithering
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestRetroPalettes(t *testing.T) {
+	for name, c := range map[string]struct {
+		pal    color.Palette
+		length int
+		known  map[int]color.RGBA
+	}{
+		"GameBoy":    {GameBoyPalette, 4, map[int]color.RGBA{0: {0x0f, 0x38, 0x0f, 255}, 3: {0x9b, 0xbc, 0x0f, 255}}},
+		"NES":        {NESPalette, 64, map[int]color.RGBA{0x01: {0, 0, 0xfc, 255}, 0x30: {0xfc, 0xfc, 0xfc, 255}}},
+		"C64":        {C64Palette, 16, map[int]color.RGBA{1: {255, 255, 255, 255}, 6: {0x35, 0x28, 0x79, 255}}},
+		"CGA":        {CGAPalette, 16, map[int]color.RGBA{6: {0xaa, 0x55, 0, 255}, 13: {0xff, 0x55, 0xff, 255}}},
+		"CGA mode 4": {CGAMode4Palette1, 4, map[int]color.RGBA{1: {0, 0xaa, 0xaa, 255}, 2: {0xaa, 0, 0xaa, 255}}},
+		"CGA mode 5": {CGAMode5Palette, 4, map[int]color.RGBA{2: {0xaa, 0, 0, 255}, 3: {0xaa, 0xaa, 0xaa, 255}}},
+		"EGA":        {EGAPalette, 16, map[int]color.RGBA{9: {0x55, 0x55, 0xff, 255}, 15: {255, 255, 255, 255}}},
+	} {
+		if len(c.pal) != c.length {
+			t.Errorf("%s: %d colors, want %d", name, len(c.pal), c.length)
+			continue
+		}
+		for i, want := range c.known {
+			if c.pal[i] != want {
+				t.Errorf("%s: color %d is %v, want %v", name, i, c.pal[i], want)
+			}
+		}
+	}
+}
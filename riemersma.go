@@ -0,0 +1,165 @@
+package dithering
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// RiemersmaDither represent Riemersma dithering algorithm implementation
+//
+// The image is traversed along a Hilbert curve and the error of the last
+// pixels is carried with decaying weights
+type RiemersmaDither struct {
+	// History is the number of previous pixels whose error is carried
+	History int
+	// Decay is the weight of the oldest error relative to the newest one
+	Decay float32
+}
+
+// NewRiemersmaDither prepares a Riemersma dithering algorithm
+// with a history of 16 pixels and a decay of 1/16
+func NewRiemersmaDither() RiemersmaDither {
+	return RiemersmaDither{History: 16, Decay: 1.0 / 16.0}
+}
+
+// hilbertPath returns every point of rect in Hilbert curve order
+//
+// The curve is the generalized Hilbert curve of Jakub Červený, which fills
+// rectangles of any size, so the path costs O(w*h) even for thin rectangles
+func hilbertPath(rect image.Rectangle) []image.Point {
+	path := make([]image.Point, 0, rect.Dx()*rect.Dy())
+	if rect.Empty() {
+		return path
+	}
+	if rect.Dx() >= rect.Dy() {
+		return gilbert(path, rect.Min.X, rect.Min.Y, rect.Dx(), 0, 0, rect.Dy())
+	}
+	return gilbert(path, rect.Min.X, rect.Min.Y, 0, rect.Dy(), rect.Dx(), 0)
+}
+
+// gilbert appends to path the points of the rectangle starting at (x, y)
+// whose major axis is (ax, ay) and minor axis is (bx, by)
+func gilbert(path []image.Point, x, y, ax, ay, bx, by int) []image.Point {
+	w, h := absInt(ax+ay), absInt(bx+by)
+	// unit vectors of the axes
+	dax, day := sign(ax), sign(ay)
+	dbx, dby := sign(bx), sign(by)
+
+	if h == 1 {
+		for i := 0; i < w; i++ {
+			path = append(path, image.Point{x, y})
+			x, y = x+dax, y+day
+		}
+		return path
+	}
+	if w == 1 {
+		for i := 0; i < h; i++ {
+			path = append(path, image.Point{x, y})
+			x, y = x+dbx, y+dby
+		}
+		return path
+	}
+
+	// the halves are rounded down, like a floor division
+	ax2, ay2 := ax>>1, ay>>1
+	bx2, by2 := bx>>1, by>>1
+	w2, h2 := absInt(ax2+ay2), absInt(bx2+by2)
+	if 2*w > 3*h {
+		// long rectangles are split in two along the major axis, the first
+		// half is kept even so that the curve can end on the next corner
+		if w2%2 == 1 && w > 2 {
+			ax2, ay2 = ax2+dax, ay2+day
+		}
+		path = gilbert(path, x, y, ax2, ay2, bx, by)
+		return gilbert(path, x+ax2, y+ay2, ax-ax2, ay-ay2, bx, by)
+	}
+
+	// other rectangles are split in three: up along the minor axis, across
+	// and back down
+	if h2%2 == 1 && h > 2 {
+		bx2, by2 = bx2+dbx, by2+dby
+	}
+	path = gilbert(path, x, y, bx2, by2, ax2, ay2)
+	path = gilbert(path, x+bx2, y+by2, ax, ay, bx-bx2, by-by2)
+	return gilbert(path, x+(ax-dax)+(bx2-dbx), y+(ay-day)+(by2-dby), -bx2, -by2, -(ax - ax2), -(ay - ay2))
+}
+
+// absInt gives the absolute value of an int
+func absInt(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// sign gives -1, 0 or 1 depending on the sign of x
+func sign(x int) int {
+	switch {
+	case x < 0:
+		return -1
+	case x > 0:
+		return 1
+	}
+	return 0
+}
+
+// Draw applies a Riemersma dithering algorithm to the src image
+//
+// Errors are ignored, use DrawE to retrieve them
+func (dit RiemersmaDither) Draw(dst draw.Image, rect image.Rectangle, src image.Image) {
+	_ = dit.DrawE(dst, rect, src)
+}
+
+// DrawE applies a Riemersma dithering algorithm to the src image
+//
+// It returns an error if the destination is not paletted or if its palette is empty
+func (dit RiemersmaDither) DrawE(dst draw.Image, rect image.Rectangle, src image.Image) error {
+	return drawE(dst, rect, src, dit.DrawWithPalette)
+}
+
+// DrawWithPalette applies a Riemersma dithering algorithm to the src image
+// using the given palette, like Dither.DrawWithPalette
+//
+// It returns an error if the palette is empty
+func (dit RiemersmaDither) DrawWithPalette(dst draw.Image, rect image.Rectangle, src image.Image, pal color.Palette) error {
+	if len(pal) == 0 {
+		return ErrEmptyPalette
+	}
+	size := dit.History
+	if size < 1 {
+		size = 1
+	}
+	// weights[0] applies to the newest error, weights[size-1] to the oldest,
+	// they are normalized so that the whole error is carried once
+	weights := make([]float32, size)
+	var sum float32
+	for i := range weights {
+		weights[i] = 1
+		if size > 1 {
+			weights[i] = float32(math.Pow(float64(dit.Decay), float64(i)/float64(size-1)))
+		}
+		sum += weights[i]
+	}
+	for i := range weights {
+		weights[i] /= sum
+	}
+
+	m := newMatcher(pal, nil)
+	out := newPaletteWriter(dst, pal)
+	history := make([]PixelError, size)
+	newest := 0
+	for _, pt := range hilbertPath(rect) {
+		var carried PixelError
+		for i, w := range weights {
+			carried = carried.Add(history[(newest+i)%size].Mul(w))
+		}
+		i, e, _ := findColor(carried, src.At(pt.X, pt.Y), m, 1)
+		out.set(pt.X, pt.Y, i)
+
+		newest = (newest + size - 1) % size
+		history[newest] = e
+	}
+	return nil
+}
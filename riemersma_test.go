@@ -0,0 +1,39 @@
+package dithering
+
+import (
+	"image"
+	"testing"
+)
+
+func TestHilbertPath(t *testing.T) {
+	for _, r := range []image.Rectangle{
+		image.Rect(0, 0, 1, 1),
+		image.Rect(0, 0, 16, 16),
+		image.Rect(3, 5, 20, 12),
+		image.Rect(-4, 2, 1, 33),
+		image.Rect(0, 0, 1000, 3),
+		image.Rect(0, 0, 2, 7),
+	} {
+		path := hilbertPath(r)
+		if len(path) != r.Dx()*r.Dy() {
+			t.Errorf("%v: %d points, want %d", r, len(path), r.Dx()*r.Dy())
+			continue
+		}
+		seen := make(map[image.Point]bool)
+		for i, p := range path {
+			if !p.In(r) || seen[p] {
+				t.Errorf("%v: point %v outside of rect or visited twice", r, p)
+				break
+			}
+			seen[p] = true
+			if i == 0 {
+				continue
+			}
+			// odd sizes may need a diagonal step
+			if d := p.Sub(path[i-1]); absInt(d.X) > 1 || absInt(d.Y) > 1 {
+				t.Errorf("%v: step from %v to %v", r, path[i-1], p)
+				break
+			}
+		}
+	}
+}
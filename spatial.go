@@ -0,0 +1,92 @@
+package dithering
+
+import (
+	"context"
+	"image"
+	"image/color"
+)
+
+// spatialIterations is the maximum number of refinements of SpatialQuantize
+const spatialIterations = 8
+
+// SpatialQuantize generates a palette of at most n colors from the src image
+// for Floyd-Steinberg dithering and returns it with the dithered image
+//
+// The palette starts from WuQuantize and is refined like k-means, except
+// that every color moves to the mean of the pixels, diffused error included,
+// that the dithering maps to it. The palette whose dithering has the lowest
+// mean error is kept. n is limited to 256 colors.
+func SpatialQuantize(src image.Image, n int) (color.Palette, *image.Paletted) {
+	b := src.Bounds()
+	if n > 256 {
+		n = 256
+	}
+	pal := WuQuantize(src, n)
+	if len(pal) == 0 {
+		return pal, image.NewPaletted(b, pal)
+	}
+	dit := NewDither(FloydSteinberg)
+	buf := NewErrorImage(b)
+	var best *image.Paletted
+	bestErr := 0.0
+	for i := 0; i < spatialIterations; i++ {
+		dst := image.NewPaletted(b, pal)
+		var stats drawStats
+		dit.draw(context.Background(), dst, b, src, pal, buf, &stats)
+		if best == nil || stats.mean() < bestErr {
+			best, bestErr = dst, stats.mean()
+		}
+		next := refinePalette(dst, buf)
+		if equalPalettes(next, pal) {
+			break
+		}
+		pal = next
+	}
+	return best.Palette, best
+}
+
+// refinePalette moves every color of the palette of dst to the mean of the
+// pixels mapped to it, their error in buf included
+//
+// Unused colors are kept as is
+func refinePalette(dst *image.Paletted, buf *ErrorImage) color.Palette {
+	sums := make([][4]float64, len(dst.Palette))
+	b := dst.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			i := dst.ColorIndexAt(x, y)
+			e := buf.PixelErrorAt(x, y)
+			sums[i][0] += float64(e.R)
+			sums[i][1] += float64(e.G)
+			sums[i][2] += float64(e.B)
+			sums[i][3]++
+		}
+	}
+	res := make(color.Palette, len(dst.Palette))
+	for i, c := range dst.Palette {
+		s := sums[i]
+		if s[3] == 0 {
+			res[i] = c
+			continue
+		}
+		col := opaqueRGBA(c)
+		ch := func(v uint8, e float64) uint8 {
+			return uint8(clampFloat(float32(float64(v)+e/s[3]+0.5), 0, 255))
+		}
+		res[i] = color.RGBA{ch(col.R, s[0]), ch(col.G, s[1]), ch(col.B, s[2]), 255}
+	}
+	return res
+}
+
+// equalPalettes tells whether two palettes have the same colors in the same order
+func equalPalettes(a, b color.Palette) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if opaqueRGBA(a[i]) != opaqueRGBA(b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,30 @@
+package dithering
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+func TestSpatialQuantize(t *testing.T) {
+	src := photo(96)
+	for _, n := range []int{4, 8, 16} {
+		pal, img := SpatialQuantize(src, n)
+		if len(pal) == 0 || len(pal) > n {
+			t.Fatalf("%d colors, want at most %d", len(pal), n)
+		}
+		// the image is the Floyd-Steinberg dithering with the palette
+		dst := image.NewPaletted(src.Rect, pal)
+		spatial, _, err := NewDither(FloydSteinberg).DrawWithStats(dst, src.Rect, src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(dst.Pix, img.Pix) {
+			t.Errorf("%d colors: the image is not the dithering with the palette", n)
+		}
+		medianCut, _, _ := NewDither(FloydSteinberg).DrawWithStats(image.NewPaletted(src.Rect, MedianCut(src, n)), src.Rect, src)
+		if spatial >= medianCut {
+			t.Errorf("%d colors: mean error %.1f, %.1f with median cut", n, spatial, medianCut)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package dithering
+
+import (
+	"context"
+	"image"
+	"image/draw"
+)
+
+// drawStats gathers the distances between the pixels and their chosen colors
+type drawStats struct {
+	sum   float64
+	max   float64
+	count int
+}
+
+// add records the distance of a pixel
+func (s *drawStats) add(distance uint32) {
+	d := float64(distance)
+	s.sum += d
+	if d > s.max {
+		s.max = d
+	}
+	s.count++
+}
+
+// mean returns the mean distance, 0 when no pixel was recorded
+func (s *drawStats) mean() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	return s.sum / float64(s.count)
+}
+
+// DrawWithStats applies an error diffusion algorithm to the src image and
+// measures the quantization error
+//
+// It returns the mean and the maximum distance between the pixels, error
+// included, and their chosen colors, measured like the matching does.
+// Transparent pixels are not measured and Parallelism is ignored.
+// It returns an error if the destination is not paletted or if its palette is empty
+func (dit Dither) DrawWithStats(dst draw.Image, rect image.Rectangle, src image.Image) (meanErr, maxErr float64, err error) {
+	pal, err := dit.paletteOf(dst)
+	if err != nil {
+		return 0, 0, err
+	}
+	var stats drawStats
+	if err := dit.draw(context.Background(), dst, rect, src, pal, nil, &stats); err != nil {
+		return 0, 0, err
+	}
+	return stats.mean(), stats.max, nil
+}
+
+// DrawWithUsage applies an error diffusion algorithm to the src image and
+// counts how many pixels of rect use every palette color
+//
+// usage[i] is the number of drawn pixels whose index is i, the counts sum to
+// the number of pixels of rect inside dst.
+// It returns an error if the destination is not paletted or if its palette is empty
+func (dit Dither) DrawWithUsage(dst draw.Image, rect image.Rectangle, src image.Image) (usage []int, err error) {
+	pal, err := dit.paletteOf(dst)
+	if err != nil {
+		return nil, err
+	}
+	if err := dit.DrawWithPalette(dst, rect, src, pal); err != nil {
+		return nil, err
+	}
+	p := dst.(*image.Paletted)
+	rect = rect.Intersect(p.Rect)
+	usage = make([]int, len(pal))
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		i := p.PixOffset(rect.Min.X, y)
+		for _, index := range p.Pix[i : i+rect.Dx()] {
+			if int(index) < len(usage) {
+				usage[index]++
+			}
+		}
+	}
+	return usage, nil
+}
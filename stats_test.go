@@ -0,0 +1,53 @@
+package dithering
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDrawWithStats(t *testing.T) {
+	src := colorful(64)
+	dit := NewDither(FloydSteinberg)
+	var prev float64
+	for i, pal := range []color.Palette{ANSI256Palette, C64Palette, blackWhite} {
+		meanErr, maxErr, err := dit.DrawWithStats(image.NewPaletted(src.Rect, pal), src.Rect, src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if meanErr <= 0 || maxErr < meanErr {
+			t.Errorf("%d colors: mean %v, max %v", len(pal), meanErr, maxErr)
+		}
+		if i > 0 && meanErr <= prev {
+			t.Errorf("%d colors: mean %v, not above %v with a richer palette", len(pal), meanErr, prev)
+		}
+		prev = meanErr
+	}
+
+	// a palette holding the source colors gives no error
+	flat := image.NewUniform(color.RGBA{0, 0, 255, 255})
+	meanErr, maxErr, _ := dit.DrawWithStats(image.NewPaletted(src.Rect, color.Palette{color.Black, flat.C}), src.Rect, flat)
+	if meanErr != 0 || maxErr != 0 {
+		t.Errorf("exact colors measure %v and %v", meanErr, maxErr)
+	}
+}
+
+func TestDrawWithUsage(t *testing.T) {
+	src := gradient(64, 16)
+	dst := image.NewPaletted(image.Rect(0, 0, 48, 16), blackWhite)
+	// rect is clipped to dst
+	usage, err := NewDither(FloydSteinberg).DrawWithUsage(dst, src.Rect, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage) != 2 || usage[0] == 0 || usage[1] == 0 {
+		t.Fatalf("usage = %v, want both colors", usage)
+	}
+	if n := usage[0] + usage[1]; n != 48*16 {
+		t.Errorf("%d pixels counted, want %d", n, 48*16)
+	}
+	if white := bytes.Count(dst.Pix, []byte{1}); white != usage[1] {
+		t.Errorf("usage = %v, %d white pixels", usage, white)
+	}
+}
@@ -0,0 +1,81 @@
+package dithering
+
+import (
+	"context"
+	"image"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// matrixReach returns how many columns a diffusion matrix reaches on each
+// side of the current pixel
+func matrixReach(matrix [][]float32) (left, right int) {
+	shift := findShift(matrix)
+	for i, row := range matrix {
+		for j, v := range row {
+			if v == 0 || (i == 0 && j+shift == 0) {
+				continue
+			}
+			if d := j + shift; d < -left {
+				left = -d
+			} else if d > right {
+				right = d
+			}
+		}
+	}
+	return left, right
+}
+
+// wavefront calls drawPixel for every pixel of rect in a staggered wavefront
+// drawn by n goroutines, each one handling every n-th row
+//
+// A row processes its pixel k once the previous row has processed k+lag
+// pixels. With a lag larger than the horizontal reach of the matrix, the
+// error of a pixel is complete before it is read, no two rows write the same
+// pixel at the same time and the errors are added in the order of the serial
+// scan. Every goroutine matches colors with its own matcher.
+func wavefront(ctx context.Context, rect image.Rectangle, n, lag int, newMatcher func() matcher, drawPixel func(m matcher, x, y int)) error {
+	width := rect.Dx()
+	progress := make([]int32, rect.Dy())
+	var stop int32
+	errc := make(chan error, n)
+	var wg sync.WaitGroup
+	for w := 0; w < n; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			m := newMatcher()
+			for row := w; row < rect.Dy(); row += n {
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					atomic.StoreInt32(&stop, 1)
+					errc <- ctxErr
+					return
+				}
+				for k := 0; k < width; k++ {
+					if row > 0 {
+						need := k + lag
+						if need > width {
+							need = width
+						}
+						for atomic.LoadInt32(&progress[row-1]) < int32(need) {
+							if atomic.LoadInt32(&stop) != 0 {
+								return
+							}
+							runtime.Gosched()
+						}
+					}
+					drawPixel(m, rect.Min.X+k, rect.Min.Y+row)
+					atomic.StoreInt32(&progress[row], int32(k+1))
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	select {
+	case err := <-errc:
+		return err
+	default:
+		return nil
+	}
+}
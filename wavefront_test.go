@@ -0,0 +1,67 @@
+package dithering
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"testing"
+)
+
+func TestParallelProgress(t *testing.T) {
+	src := gradient(64, 32)
+	r := src.Bounds()
+	dit := NewDither(FloydSteinberg)
+	dit.Parallelism = 4
+	var done, total int
+	dit.Progress = func(d, t int) { done, total = d, t }
+	dit.Draw(image.NewPaletted(r, blackWhite), r, src)
+	if n := r.Dx() * r.Dy(); done != n || total != n {
+		t.Errorf("Progress(%d, %d), want Progress(%d, %d)", done, total, n, n)
+	}
+}
+
+func TestParallelSerial(t *testing.T) {
+	src := colorful(67)
+	r := src.Bounds()
+	for _, name := range MatrixNames() {
+		dit, _ := DitherByName(name)
+		want := image.NewPaletted(r, C64Palette)
+		dit.Draw(want, r, src)
+		for _, n := range []int{2, 3, 8, 100} {
+			dit.Parallelism = n
+			got := image.NewPaletted(r, C64Palette)
+			dit.Draw(got, r, src)
+			if !bytes.Equal(got.Pix, want.Pix) {
+				t.Errorf("%s: %d goroutines differ from the serial scan", name, n)
+			}
+		}
+	}
+	// the integer path and the cache too
+	cached := NewDither(Stucki)
+	cached.Cache = true
+	for _, dit := range []Dither{NewDitherInt(JarvisJudiceNinkeInt), cached} {
+		want := image.NewPaletted(r, ANSI256Palette)
+		dit.Draw(want, r, src)
+		dit.Parallelism = 4
+		got := image.NewPaletted(r, ANSI256Palette)
+		dit.Draw(got, r, src)
+		if !bytes.Equal(got.Pix, want.Pix) {
+			t.Errorf("%s: 4 goroutines differ from the serial scan", dit.MatrixName())
+		}
+	}
+}
+
+func BenchmarkParallel(b *testing.B) {
+	src := colorful(4096)
+	r := src.Bounds()
+	dst := image.NewPaletted(r, C64Palette)
+	for _, n := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+			dit := NewDither(FloydSteinberg)
+			dit.Parallelism = n
+			for i := 0; i < b.N; i++ {
+				dit.Draw(dst, r, src)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package dithering
+
+import (
+	"image"
+	"image/color"
+)
+
+// wuSide is the number of cells per axis of the Wu histogram, the channels
+// are reduced to 5 bits and the cells are shifted by one so that the
+// cumulative moments have a zero plane
+const wuSide = 33
+
+// wuMoment holds a cumulative moment of the Wu histogram
+type wuMoment [wuSide * wuSide * wuSide]float64
+
+// wuAxis is an axis of the RGB cube
+type wuAxis int
+
+const (
+	wuRed wuAxis = iota
+	wuGreen
+	wuBlue
+)
+
+// wuIndex returns the index of a cell of the Wu histogram
+func wuIndex(r, g, b int) int {
+	return (r*wuSide+g)*wuSide + b
+}
+
+// wuMoments are the cumulative moments of the colors of an image
+type wuMoments struct {
+	// w counts the pixels, r, g and b sum their channels and m2 their
+	// squared channels
+	w, r, g, b, m2 wuMoment
+}
+
+// newWuMoments builds the cumulative moments of the colors of src
+func newWuMoments(src image.Image) *wuMoments {
+	m := &wuMoments{}
+	bounds := src.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := opaqueRGBA(src.At(x, y))
+			i := wuIndex(int(c.R>>3)+1, int(c.G>>3)+1, int(c.B>>3)+1)
+			r, g, b := float64(c.R), float64(c.G), float64(c.B)
+			m.w[i]++
+			m.r[i] += r
+			m.g[i] += g
+			m.b[i] += b
+			m.m2[i] += r*r + g*g + b*b
+		}
+	}
+	for _, mom := range []*wuMoment{&m.w, &m.r, &m.g, &m.b, &m.m2} {
+		for r := 1; r < wuSide; r++ {
+			var area [wuSide]float64
+			for g := 1; g < wuSide; g++ {
+				var line float64
+				for b := 1; b < wuSide; b++ {
+					line += mom[wuIndex(r, g, b)]
+					area[b] += line
+					mom[wuIndex(r, g, b)] = mom[wuIndex(r-1, g, b)] + area[b]
+				}
+			}
+		}
+	}
+	return m
+}
+
+// wuBox is a box of the Wu histogram, the lower bounds are exclusive
+type wuBox struct {
+	r0, r1, g0, g1, b0, b1 int
+}
+
+// cells returns the number of cells of the box
+func (b wuBox) cells() int {
+	return (b.r1 - b.r0) * (b.g1 - b.g0) * (b.b1 - b.b0)
+}
+
+// volume sums a moment over the box
+func (b wuBox) volume(m *wuMoment) float64 {
+	return m[wuIndex(b.r1, b.g1, b.b1)] - m[wuIndex(b.r1, b.g1, b.b0)] -
+		m[wuIndex(b.r1, b.g0, b.b1)] + m[wuIndex(b.r1, b.g0, b.b0)] -
+		m[wuIndex(b.r0, b.g1, b.b1)] + m[wuIndex(b.r0, b.g1, b.b0)] +
+		m[wuIndex(b.r0, b.g0, b.b1)] - m[wuIndex(b.r0, b.g0, b.b0)]
+}
+
+// bottom is the part of the volume of a moment that does not depend on the
+// position of a cut along the axis
+func (b wuBox) bottom(axis wuAxis, m *wuMoment) float64 {
+	switch axis {
+	case wuRed:
+		return -m[wuIndex(b.r0, b.g1, b.b1)] + m[wuIndex(b.r0, b.g1, b.b0)] +
+			m[wuIndex(b.r0, b.g0, b.b1)] - m[wuIndex(b.r0, b.g0, b.b0)]
+	case wuGreen:
+		return -m[wuIndex(b.r1, b.g0, b.b1)] + m[wuIndex(b.r1, b.g0, b.b0)] +
+			m[wuIndex(b.r0, b.g0, b.b1)] - m[wuIndex(b.r0, b.g0, b.b0)]
+	default:
+		return -m[wuIndex(b.r1, b.g1, b.b0)] + m[wuIndex(b.r1, b.g0, b.b0)] +
+			m[wuIndex(b.r0, b.g1, b.b0)] - m[wuIndex(b.r0, b.g0, b.b0)]
+	}
+}
+
+// top is the part of the volume of a moment that depends on the position
+// of a cut along the axis
+func (b wuBox) top(axis wuAxis, pos int, m *wuMoment) float64 {
+	switch axis {
+	case wuRed:
+		return m[wuIndex(pos, b.g1, b.b1)] - m[wuIndex(pos, b.g1, b.b0)] -
+			m[wuIndex(pos, b.g0, b.b1)] + m[wuIndex(pos, b.g0, b.b0)]
+	case wuGreen:
+		return m[wuIndex(b.r1, pos, b.b1)] - m[wuIndex(b.r1, pos, b.b0)] -
+			m[wuIndex(b.r0, pos, b.b1)] + m[wuIndex(b.r0, pos, b.b0)]
+	default:
+		return m[wuIndex(b.r1, b.g1, pos)] - m[wuIndex(b.r1, b.g0, pos)] -
+			m[wuIndex(b.r0, b.g1, pos)] + m[wuIndex(b.r0, b.g0, pos)]
+	}
+}
+
+// variance returns the sum of the squared distances of the pixels of the box
+// to their mean
+func (m *wuMoments) variance(b wuBox) float64 {
+	r, g, bl, w := b.volume(&m.r), b.volume(&m.g), b.volume(&m.b), b.volume(&m.w)
+	if w == 0 {
+		return 0
+	}
+	return b.volume(&m.m2) - (r*r+g*g+bl*bl)/w
+}
+
+// maximize looks for the cut of the box along the axis that minimizes the
+// variance of the two halves, it returns the cut and how good it is and a
+// negative cut when the box cannot be split along the axis
+func (m *wuMoments) maximize(b wuBox, axis wuAxis, whole [4]float64) (float64, int) {
+	moments := [4]*wuMoment{&m.r, &m.g, &m.b, &m.w}
+	var base [4]float64
+	for k, mom := range moments {
+		base[k] = b.bottom(axis, mom)
+	}
+	first, last := b.r0+1, b.r1
+	if axis == wuGreen {
+		first, last = b.g0+1, b.g1
+	} else if axis == wuBlue {
+		first, last = b.b0+1, b.b1
+	}
+
+	var max float64
+	cut := -1
+	for pos := first; pos < last; pos++ {
+		var half, rest [4]float64
+		for k, mom := range moments {
+			half[k] = base[k] + b.top(axis, pos, mom)
+			rest[k] = whole[k] - half[k]
+		}
+		if half[3] == 0 || rest[3] == 0 {
+			continue
+		}
+		score := (half[0]*half[0]+half[1]*half[1]+half[2]*half[2])/half[3] +
+			(rest[0]*rest[0]+rest[1]*rest[1]+rest[2]*rest[2])/rest[3]
+		if score > max {
+			max, cut = score, pos
+		}
+	}
+	return max, cut
+}
+
+// cut splits the box b1 in two, b1 keeps the lower half and b2 receives the
+// upper half, it returns false when the box cannot be split
+func (m *wuMoments) cut(b1, b2 *wuBox) bool {
+	whole := [4]float64{b1.volume(&m.r), b1.volume(&m.g), b1.volume(&m.b), b1.volume(&m.w)}
+	maxR, cutR := m.maximize(*b1, wuRed, whole)
+	maxG, cutG := m.maximize(*b1, wuGreen, whole)
+	maxB, cutB := m.maximize(*b1, wuBlue, whole)
+
+	*b2 = *b1
+	switch {
+	case maxR >= maxG && maxR >= maxB:
+		if cutR < 0 {
+			return false
+		}
+		b1.r1, b2.r0 = cutR, cutR
+	case maxG >= maxR && maxG >= maxB:
+		b1.g1, b2.g0 = cutG, cutG
+	default:
+		b1.b1, b2.b0 = cutB, cutB
+	}
+	return true
+}
+
+// WuQuantize generates a palette of at most n colors from the src image with
+// the Wu quantizer
+//
+// The colors are gathered in a histogram with 5 bits per channel and the box
+// whose split reduces the variance the most is split until there are n boxes,
+// every box then gives the mean of its pixels. It is more expensive than
+// MedianCut but the palette usually has a lower quantization error.
+func WuQuantize(src image.Image, n int) color.Palette {
+	if n < 1 || src.Bounds().Empty() {
+		return color.Palette{}
+	}
+	m := newWuMoments(src)
+	boxes := make([]wuBox, n)
+	boxes[0] = wuBox{0, wuSide - 1, 0, wuSide - 1, 0, wuSide - 1}
+	variances := make([]float64, n)
+	count := n
+	next := 0
+	for i := 1; i < n; i++ {
+		if m.cut(&boxes[next], &boxes[i]) {
+			variances[next], variances[i] = 0, 0
+			if boxes[next].cells() > 1 {
+				variances[next] = m.variance(boxes[next])
+			}
+			if boxes[i].cells() > 1 {
+				variances[i] = m.variance(boxes[i])
+			}
+		} else {
+			// the box cannot be split, another one is tried
+			variances[next] = 0
+			i--
+		}
+		next = 0
+		for k := 1; k <= i; k++ {
+			if variances[k] > variances[next] {
+				next = k
+			}
+		}
+		if variances[next] <= 0 {
+			count = i + 1
+			break
+		}
+	}
+
+	pal := make(color.Palette, 0, count)
+	for _, b := range boxes[:count] {
+		w := b.volume(&m.w)
+		if w == 0 {
+			continue
+		}
+		pal = append(pal, color.RGBA{
+			uint8(b.volume(&m.r)/w + 0.5),
+			uint8(b.volume(&m.g)/w + 0.5),
+			uint8(b.volume(&m.b)/w + 0.5),
+			255})
+	}
+	return pal
+}
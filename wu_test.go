@@ -0,0 +1,51 @@
+package dithering
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// photo returns a size x size image of smooth shapes and grain, like a photograph
+func photo(size int) *image.RGBA {
+	rnd := rand.New(rand.NewSource(1))
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			u, v := float64(x)/float64(size), float64(y)/float64(size)
+			ch := func(f, phase float64) uint8 {
+				s := 128 + 90*math.Sin(f*u+phase)*math.Cos(f*v-phase) + rnd.NormFloat64()*8
+				return uint8(math.Max(0, math.Min(255, s)))
+			}
+			img.SetRGBA(x, y, color.RGBA{ch(5, 0), ch(3, 1), ch(7, 2), 255})
+		}
+	}
+	return img
+}
+
+// quantizationError returns the mean distance between the pixels of src and
+// their closest color of pal
+func quantizationError(t *testing.T, src image.Image, pal color.Palette) float64 {
+	t.Helper()
+	meanErr, _, err := NewThresholdDither().DrawWithStats(image.NewPaletted(src.Bounds(), pal), src.Bounds(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return meanErr
+}
+
+func TestWuQuantize(t *testing.T) {
+	src := photo(128)
+	for _, n := range []int{4, 16, 64} {
+		pal := WuQuantize(src, n)
+		if len(pal) != n {
+			t.Errorf("%d colors, want %d", len(pal), n)
+		}
+		wu, popularity := quantizationError(t, src, pal), quantizationError(t, src, PopularityPalette(src, n, 5))
+		if wu >= popularity {
+			t.Errorf("%d colors: mean error %.1f, %.1f with the popularity palette", n, wu, popularity)
+		}
+	}
+}